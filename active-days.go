@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wcharczuk/go-chart"
+)
+
+// activeDays counts the calendar days in loc with at least one of the sorted
+// activities, the days -merge-daily would leave.
+func activeDays(activities Activities, loc *time.Location) int {
+	return len(mergeDaily(activities, loc))
+}
+
+// activeDaysElement draws "102 active days" for -annotate-active-days above
+// the top right corner of the plot, raising the top padding to make room.
+func activeDaysElement(opts Options, activities Activities, padding *chart.Box) chart.Renderable {
+	fontSize := 10.0
+	if opts.fontSize > 0 {
+		fontSize = opts.fontSize
+	}
+	if need := int(fontSize*2) + 8; padding.GetTop() < need {
+		padding.Top = need
+	}
+	label := fmt.Sprintf("%d active days", activeDays(dailyActivities(opts, activities), opts.location))
+	if label == "1 active days" {
+		label = "1 active day"
+	}
+	return func(r chart.Renderer, canvas chart.Box, defaults chart.Style) {
+		style := chart.Style{Font: defaults.Font, FontSize: fontSize, FontColor: chart.DefaultTextColor}
+		style.WriteToRenderer(r)
+		chart.Draw.Text(r, label, canvas.Right-r.MeasureText(label).Width(), canvas.Top-int(fontSize/2)-2, style)
+	}
+}
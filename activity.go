@@ -0,0 +1,230 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+type Activity struct {
+	Name         string
+	Duration     int64
+	Distance     float64
+	Description  string
+	Date         time.Time
+	ActivityType int64
+	HeartRate    float64
+	Elevation    float64
+	// ActiveMinutes is Fit's count of minutes of brisk movement, which can be
+	// less than Duration
+	ActiveMinutes int64
+	// Calories is the energy expended in kcal
+	Calories float64
+	Steps    int64
+	// Has records which of the optional values above came with data, so a
+	// missing one can be told apart from a real zero
+	Has fields
+}
+
+// fields is a set of an Activity's optional values.
+type fields uint8
+
+const (
+	hasHeartRate fields = 1 << iota
+	hasElevation
+	hasActiveMinutes
+	hasCalories
+	hasSteps
+)
+
+type Activities []Activity
+
+func (e Activities) Len() int {
+	return len(e)
+}
+
+func (e Activities) Less(i, j int) bool {
+	return e[i].Date.Before(e[j].Date)
+}
+
+func (e Activities) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+}
+
+// removeDuplicatesWithin treats activities starting within window of each
+// other with similar distances as duplicates, such as the same run synced by
+// Fit and imported from a file, whose clocks rarely agree to the second. Of
+// each set it keeps the most complete. activities must be sorted.
+func removeDuplicatesWithin(activities Activities, window time.Duration) Activities {
+	var dedupe Activities
+	for _, activity := range activities {
+		dup := false
+		for j := len(dedupe) - 1; j >= 0 && activity.Date.Sub(dedupe[j].Date) <= window; j-- {
+			if !similarDistance(activity.Distance, dedupe[j].Distance) {
+				continue
+			}
+			dup = true
+			if completeness(activity) > completeness(dedupe[j]) {
+				dedupe[j] = activity
+			}
+			break
+		}
+		if !dup {
+			dedupe = append(dedupe, activity)
+		}
+	}
+	return dedupe
+}
+
+// similarDistance reports whether two distances in miles are within 10% or a
+// tenth of a mile of each other, as two recordings of one activity would be.
+func similarDistance(a, b float64) bool {
+	return math.Abs(a-b) <= math.Max(0.1, 0.1*math.Max(a, b))
+}
+
+// completeness counts how many of an activity's values are filled in.
+func completeness(a Activity) int {
+	n := bits.OnesCount8(uint8(a.Has))
+	for _, set := range []bool{a.Name != "", a.Description != "", a.Distance > 0, a.Duration > 0} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+func removeDuplicates(activities Activities) Activities {
+	var dedupe Activities
+	seen := map[string]bool{}
+	for _, activity := range activities {
+		if seen[activity.Date.String()] == false {
+			dedupe = append(dedupe, activity)
+			seen[activity.Date.String()] = true
+		}
+	}
+	return dedupe
+}
+
+// dropOutliers removes any activity further than max in unit, which is almost
+// always a GPS glitch rather than a real workout.
+func dropOutliers(activities Activities, max float64, unit string, precision int) Activities {
+	var kept Activities
+	for _, activity := range activities {
+		if d := convertDistance(activity.Distance, unit); d > max {
+			infof("dropping %q on %s: %s %s exceeds -max-activity-distance\n", activity.Name, activity.Date.Format("2006-01-02"), formatNumber(d, precision), unit)
+			continue
+		}
+		kept = append(kept, activity)
+	}
+	return kept
+}
+
+// mergeDaily combines sorted activities on the same calendar day in loc into
+// one, summing distance, duration, elevation and active minutes. Heart rate is
+// averaged over the activities that have one, weighted by duration, and the
+// day keeps the first activity's time and type.
+func mergeDaily(activities Activities, loc *time.Location) Activities {
+	var merged Activities
+	// heartMinutes sums heart rate times duration over hrMinutes of activities
+	// with a heart rate, so those without one don't pull the average down
+	var heartMinutes, hrMinutes float64
+	heartRate := func(a Activity) (float64, float64) {
+		if a.Has&hasHeartRate == 0 {
+			return 0, 0
+		}
+		return a.HeartRate * float64(a.Duration), float64(a.Duration)
+	}
+	for _, activity := range activities {
+		last := len(merged) - 1
+		if last >= 0 && sameDay(merged[last].Date, activity.Date, loc) {
+			day := &merged[last]
+			day.Distance += activity.Distance
+			day.Duration += activity.Duration
+			day.Elevation += activity.Elevation
+			day.ActiveMinutes += activity.ActiveMinutes
+			day.Calories += activity.Calories
+			day.Steps += activity.Steps
+			day.Has |= activity.Has
+			hm, m := heartRate(activity)
+			heartMinutes += hm
+			hrMinutes += m
+			if hrMinutes > 0 {
+				day.HeartRate = heartMinutes / hrMinutes
+			}
+			if activity.Name != "" && activity.Name != day.Name {
+				day.Name += ", " + activity.Name
+			}
+			continue
+		}
+		merged = append(merged, activity)
+		heartMinutes, hrMinutes = heartRate(activity)
+	}
+	return merged
+}
+
+// splitAtMidnight splits every activity running past midnight in loc into
+// one part per day for -split-midnight, sharing out distance, duration and
+// the other totals by the time spent on each day. Without it an activity
+// counts entirely towards the day it started on.
+func splitAtMidnight(activities Activities, loc *time.Location) Activities {
+	var split Activities
+	for _, a := range activities {
+		start := a.Date.In(loc)
+		end := start.Add(time.Duration(a.Duration) * time.Minute)
+		midnight := time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, loc)
+		if !end.After(midnight) {
+			split = append(split, a)
+			continue
+		}
+		total := end.Sub(start).Seconds()
+		// whole-unit totals are shared by rounding the running share, so the
+		// parts still add up to the original
+		share := func(v int64, from, to time.Time) int64 {
+			return int64(math.Round(float64(v)*to.Sub(start).Seconds()/total)) -
+				int64(math.Round(float64(v)*from.Sub(start).Seconds()/total))
+		}
+		for from := start; from.Before(end); {
+			to := time.Date(from.Year(), from.Month(), from.Day()+1, 0, 0, 0, 0, loc)
+			if to.After(end) {
+				to = end
+			}
+			fraction := to.Sub(from).Seconds() / total
+			part := a
+			part.Date = from
+			part.Duration = share(a.Duration, from, to)
+			part.Distance = a.Distance * fraction
+			part.Elevation = a.Elevation * fraction
+			part.Calories = a.Calories * fraction
+			part.ActiveMinutes = share(a.ActiveMinutes, from, to)
+			part.Steps = share(a.Steps, from, to)
+			if !from.Equal(start) {
+				// annotated once, on the day it started
+				part.Description = ""
+			}
+			split = append(split, part)
+			from = to
+		}
+	}
+	return split
+}
+
+// dailyActivities is activities as the per-day outputs (-merge-daily,
+// -calendar and the active days) see them: split at midnight for
+// -split-midnight, otherwise as they are. Everything listing or counting
+// activities uses the originals.
+func dailyActivities(opts Options, activities Activities) Activities {
+	if !opts.splitMidnight {
+		return activities
+	}
+	split := splitAtMidnight(activities, opts.location)
+	// the later parts start at midnight, possibly after other activities
+	sort.Sort(split)
+	return split
+}
+
+// sameDay reports whether a and b fall on the same calendar day in loc.
+func sameDay(a, b time.Time, loc *time.Location) bool {
+	a, b = a.In(loc), b.In(loc)
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSplitAtMidnight(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		start     time.Time
+		minutes   int64
+		wantDates []time.Time
+		wantMins  []int64
+	}{
+		{
+			name:      "across midnight",
+			start:     time.Date(2021, 6, 1, 23, 0, 0, 0, chicago),
+			minutes:   120,
+			wantDates: []time.Time{time.Date(2021, 6, 1, 23, 0, 0, 0, chicago), time.Date(2021, 6, 2, 0, 0, 0, 0, chicago)},
+			wantMins:  []int64{60, 60},
+		},
+		{
+			// the spring forward day is 23 hours long
+			name:      "across a DST change",
+			start:     time.Date(2021, 3, 13, 23, 0, 0, 0, chicago),
+			minutes:   60 + 23*60 + 30,
+			wantDates: []time.Time{time.Date(2021, 3, 13, 23, 0, 0, 0, chicago), time.Date(2021, 3, 14, 0, 0, 0, 0, chicago), time.Date(2021, 3, 15, 0, 0, 0, 0, chicago)},
+			wantMins:  []int64{60, 23 * 60, 30},
+		},
+		{
+			name:      "ending exactly at midnight",
+			start:     time.Date(2021, 6, 1, 23, 0, 0, 0, chicago),
+			minutes:   60,
+			wantDates: []time.Time{time.Date(2021, 6, 1, 23, 0, 0, 0, chicago)},
+			wantMins:  []int64{60},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Activity{Date: tt.start, Duration: tt.minutes, Distance: 10, Steps: 1001, Description: "night ride"}
+			parts := splitAtMidnight(Activities{a}, chicago)
+			if len(parts) != len(tt.wantDates) {
+				t.Fatalf("got %d parts, want %d", len(parts), len(tt.wantDates))
+			}
+			distance, steps := 0.0, int64(0)
+			for i, p := range parts {
+				if !p.Date.Equal(tt.wantDates[i]) {
+					t.Errorf("part %d starts at %s, want %s", i, p.Date, tt.wantDates[i])
+				}
+				if p.Duration != tt.wantMins[i] {
+					t.Errorf("part %d lasts %d minutes, want %d", i, p.Duration, tt.wantMins[i])
+				}
+				wantDistance := 10 * float64(tt.wantMins[i]) / float64(tt.minutes)
+				if math.Abs(p.Distance-wantDistance) > 1e-9 {
+					t.Errorf("part %d has distance %g, want %g", i, p.Distance, wantDistance)
+				}
+				if i > 0 && p.Description != "" {
+					t.Errorf("part %d kept the description", i)
+				}
+				distance += p.Distance
+				steps += p.Steps
+			}
+			if math.Abs(distance-10) > 1e-9 || steps != 1001 {
+				t.Errorf("parts add up to %g and %d steps, want 10 and 1001", distance, steps)
+			}
+		})
+	}
+}
+
+func TestSplitMidnightOnlyForDailyOutputs(t *testing.T) {
+	opts := Options{splitMidnight: true, location: time.UTC}
+	activities := Activities{
+		{Date: time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC), Duration: 30, Distance: 5},
+		{Date: time.Date(2021, 6, 1, 23, 0, 0, 0, time.UTC), Duration: 120, Distance: 10},
+		{Date: time.Date(2021, 6, 2, 9, 0, 0, 0, time.UTC), Duration: 30, Distance: 5},
+	}
+	if got := computeStats(activities, "mi").count; got != 3 {
+		t.Errorf("stats count %d activities, want 3", got)
+	}
+	if got := len(dailyActivities(opts, activities)); got != 4 {
+		t.Errorf("dailyActivities returned %d parts, want 4", got)
+	}
+	if len(activities) != 3 {
+		t.Errorf("dailyActivities changed the original activities")
+	}
+	merged := mergeDaily(dailyActivities(opts, activities), opts.location)
+	if len(merged) != 2 || merged[0].Distance != 10 || merged[1].Distance != 10 {
+		t.Errorf("mergeDaily of the split activities = %+v, want 10 on each day", merged)
+	}
+	if got := activeDays(dailyActivities(opts, activities), opts.location); got != 2 {
+		t.Errorf("activeDays = %d, want 2", got)
+	}
+}
+
+func TestRemoveDuplicatesWithin(t *testing.T) {
+	start := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	ride := Activity{Date: start, Distance: 10, ActivityType: 1, Duration: 40}
+	tests := []struct {
+		name   string
+		second Activity
+		want   int
+	}{
+		{"exactly at the window", Activity{Date: start.Add(5 * time.Minute), Distance: 10, ActivityType: 1}, 1},
+		{"just outside the window", Activity{Date: start.Add(5*time.Minute + time.Second), Distance: 10, ActivityType: 1}, 2},
+		// an import often types the same ride differently from Fit
+		{"across activity types", Activity{Date: start.Add(time.Minute), Distance: 10.5, ActivityType: 108}, 1},
+		{"different distance", Activity{Date: start.Add(time.Minute), Distance: 3, ActivityType: 1}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := removeDuplicatesWithin(Activities{ride, tt.second}, 5*time.Minute)
+			if len(got) != tt.want {
+				t.Errorf("kept %d activities, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveDuplicatesWithinKeepsMostComplete(t *testing.T) {
+	start := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	sparse := Activity{Date: start, Distance: 10}
+	full := Activity{Date: start.Add(time.Minute), Distance: 10, Name: "Ride", Duration: 40, HeartRate: 140, Has: hasHeartRate}
+	got := removeDuplicatesWithin(Activities{sparse, full}, 5*time.Minute)
+	if len(got) != 1 || got[0].Name != "Ride" {
+		t.Errorf("got %+v, want only the named activity", got)
+	}
+}
+
+func TestMergeDailyHeartRate(t *testing.T) {
+	morning := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		activities Activities
+		wantHR     float64
+		wantHas    bool
+	}{
+		{
+			name: "one with heart rate and one without",
+			activities: Activities{
+				{Date: morning, Duration: 30, HeartRate: 150, Has: hasHeartRate},
+				{Date: morning.Add(time.Hour), Duration: 90},
+			},
+			wantHR:  150,
+			wantHas: true,
+		},
+		{
+			name: "without heart rate first",
+			activities: Activities{
+				{Date: morning, Duration: 90},
+				{Date: morning.Add(time.Hour), Duration: 30, HeartRate: 150, Has: hasHeartRate},
+			},
+			wantHR:  150,
+			wantHas: true,
+		},
+		{
+			name: "weighted by duration",
+			activities: Activities{
+				{Date: morning, Duration: 30, HeartRate: 120, Has: hasHeartRate},
+				{Date: morning.Add(time.Hour), Duration: 90, HeartRate: 160, Has: hasHeartRate},
+			},
+			wantHR:  150,
+			wantHas: true,
+		},
+		{
+			name: "none with heart rate",
+			activities: Activities{
+				{Date: morning, Duration: 30},
+				{Date: morning.Add(time.Hour), Duration: 90},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergeDaily(tt.activities, time.UTC)
+			if len(merged) != 1 {
+				t.Fatalf("got %d days, want 1", len(merged))
+			}
+			if math.Abs(merged[0].HeartRate-tt.wantHR) > 1e-9 {
+				t.Errorf("got heart rate %v, want %v", merged[0].HeartRate, tt.wantHR)
+			}
+			if has := merged[0].Has&hasHeartRate != 0; has != tt.wantHas {
+				t.Errorf("got hasHeartRate %v, want %v", has, tt.wantHas)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// svgPath matches each path element go-chart writes.
+var svgPath = regexp.MustCompile(`<path [^>]*>`)
+
+// svgAnimation draws each .grow path from its start to its end once. With
+// pathLength="1" a dash as long as the whole path can slide into view
+// without knowing how long the path really is.
+const svgAnimation = `<style>.grow{stroke-dasharray:1;stroke-dashoffset:1;animation:grow 4s ease-in-out forwards}` +
+	`@keyframes grow{to{stroke-dashoffset:0}}</style>`
+
+// animateSVG adds a CSS animation to a rendered chart for -animate that draws
+// each data line from start to finish. Lines are told apart from axes, ticks
+// and goal lines by being unfilled, undashed and having more than a couple of
+// segments; everything else is left alone.
+func animateSVG(svg []byte) []byte {
+	svg = svgPath.ReplaceAllFunc(svg, func(path []byte) []byte {
+		if !bytes.Contains(path, []byte("fill:none")) || bytes.Contains(path, []byte("dasharray")) ||
+			bytes.Count(path, []byte("L ")) < 3 {
+			return path
+		}
+		return bytes.Replace(path, []byte("<path "), []byte(`<path pathLength="1" class="grow" `), 1)
+	})
+	// the style goes right after the opening svg tag
+	if i := bytes.IndexByte(svg, '>'); i >= 0 {
+		svg = append(svg[:i+1], append([]byte(svgAnimation), svg[i+1:]...)...)
+	}
+	return svg
+}
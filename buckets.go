@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wcharczuk/go-chart"
+)
+
+// bucket is a labelled period from start up to but not including end.
+type bucket struct {
+	label      string
+	start, end time.Time
+}
+
+// readBucketsFile parses one boundary per line as "YYYY-MM-DD [label]". Each
+// line starts a period that runs until the next line, so the final line only
+// marks the end. Blank lines and lines starting with # are skipped.
+func readBucketsFile(path string, loc *time.Location) ([]bucket, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var starts []time.Time
+	var labels []string
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		date, label, _ := strings.Cut(text, " ")
+		t, err := time.ParseInLocation("2006-01-02", date, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if len(starts) > 0 && !t.After(starts[len(starts)-1]) {
+			return nil, fmt.Errorf("%s:%d: boundaries must be in increasing order", path, line)
+		}
+		starts = append(starts, t)
+		labels = append(labels, strings.TrimSpace(label))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(starts) < 2 {
+		return nil, fmt.Errorf("%s: need at least two boundaries", path)
+	}
+
+	var buckets []bucket
+	for i := 0; i < len(starts)-1; i++ {
+		label := labels[i]
+		if label == "" {
+			label = starts[i].Format("Jan 2")
+		}
+		buckets = append(buckets, bucket{label: label, start: starts[i], end: starts[i+1]})
+	}
+	return buckets, nil
+}
+
+// periodBuckets splits start to end into calendar weeks (starting on
+// weekStart), months or quarters for -bucket. The first and last periods are whole, so
+// they may reach outside the range, and periods with no activities are kept
+// so the timeline has no gaps.
+func periodBuckets(period string, start, end time.Time, weekStart time.Weekday) []bucket {
+	from := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	step := func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	label := func(t time.Time) string { return t.Format("Jan 2") }
+	switch period {
+	case "month":
+		from = from.AddDate(0, 0, 1-from.Day())
+		step = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+		label = func(t time.Time) string { return t.Format("Jan 2006") }
+	case "quarter":
+		from = time.Date(from.Year(), (from.Month()-1)/3*3+1, 1, 0, 0, 0, 0, from.Location())
+		step = func(t time.Time) time.Time { return t.AddDate(0, 3, 0) }
+		label = func(t time.Time) string { return fmt.Sprintf("%d-Q%d", t.Year(), (t.Month()-1)/3+1) }
+	default:
+		from = from.AddDate(0, 0, -(int(from.Weekday())-int(weekStart)+7)%7)
+	}
+
+	var buckets []bucket
+	for t := from; !t.After(end); t = step(t) {
+		buckets = append(buckets, bucket{label: label(t), start: t, end: step(t)})
+	}
+	return buckets
+}
+
+// sumBuckets totals the metric for the activities starting within each bucket.
+func sumBuckets(opts Options, activities Activities, buckets []bucket) []float64 {
+	sums := make([]float64, len(buckets))
+	m := metrics[opts.metric]
+	for _, activity := range activities {
+		for i, b := range buckets {
+			if !activity.Date.Before(b.start) && activity.Date.Before(b.end) {
+				sums[i] += m.value(activity, opts)
+				break
+			}
+		}
+	}
+	return sums
+}
+
+// buildBucketChart draws one bar per bucket.
+func buildBucketChart(opts Options, activities Activities, buckets []bucket) chart.BarChart {
+	var bars []chart.Value
+	maxY := 1.0
+	for i, sum := range sumBuckets(opts, activities, buckets) {
+		bars = append(bars, chart.Value{Value: sum, Label: buckets[i].label})
+		maxY = math.Max(maxY, sum)
+	}
+	title := metrics[opts.metric].label(opts)
+	if opts.yLabel != "" {
+		title = opts.yLabel
+	}
+	graph := chart.BarChart{
+		Title: title,
+		Background: chart.Style{
+			Padding: chart.Box{Top: 40},
+		},
+		YAxis: chart.YAxis{
+			Range: &chart.ContinuousRange{Min: 0, Max: maxY},
+			ValueFormatter: func(v interface{}) string {
+				return formatNumber(v.(float64), opts.precision)
+			},
+		},
+		BarWidth: 40,
+		Bars:     bars,
+	}
+	applyBarLayout(opts, &graph)
+	if opts.annotateActiveDays {
+		graph.Elements = append(graph.Elements, activeDaysElement(opts, activities, &graph.Background.Padding))
+	}
+	return graph
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// renderCache is a fixed size LRU of rendered charts, or for -serve the
+// activities they were drawn from or the responses behind them, whose
+// entries expire after ttl.
+type renderCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+func newRenderCache(size int, ttl time.Duration) *renderCache {
+	return &renderCache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+// get returns the value stored under key if it hasn't expired.
+func (c *renderCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return entry.value, true
+}
+
+// add stores value under key, evicting the least recently used entry when full.
+func (c *renderCache) add(key string, value interface{}) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.ll.Remove(el)
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, value: value, expires: time.Now().Add(c.ttl)})
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// cacheKey normalizes every option that affects output into a string. Pointer
+// fields are swapped for their string forms so equal options give equal keys;
+// that includes the times, which print their *time.Location and would differ
+// for each parse of a named -tz.
+func cacheKey(opts Options, format string) string {
+	var re string
+	if opts.nameRegex != nil {
+		re = opts.nameRegex.String()
+	}
+	loc := opts.location.String()
+	times := []string{
+		opts.start.Format(time.RFC3339Nano),
+		opts.end.Format(time.RFC3339Nano),
+		opts.planStart.Format(time.RFC3339Nano),
+	}
+	for _, n := range opts.notes {
+		times = append(times, n.date.Format(time.RFC3339Nano)+"="+n.label)
+	}
+	opts.nameRegex = nil
+	opts.location = nil
+	opts.formats = nil
+	opts.start, opts.end, opts.planStart = time.Time{}, time.Time{}, time.Time{}
+	opts.notes = nil
+	return fmt.Sprintf("%s|%s|%s|%q|%+v", format, re, loc, times, opts)
+}
+
+// fetchKey is cacheKey for the activities -serve fetches, keyed only by the
+// options that change what is fetched. Every metric but effort's elevation
+// is fetched each time, so switching between the others can reuse a fetch.
+func fetchKey(opts Options) string {
+	return fmt.Sprintf("%d|%d|%v|%s|%d|%t|%s|%d|%t|%t|%s|%s|%s|%s",
+		opts.start.UnixNano(), opts.end.UnixNano(), opts.types, opts.location, opts.chunk,
+		opts.noSessions, opts.badTimestamps, opts.minSessions, usesMetric(opts, "effort"),
+		opts.demo, opts.fromJSON, opts.importDir, opts.session, opts.sessionBucket)
+}
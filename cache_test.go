@@ -0,0 +1,29 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheKeySameArgs(t *testing.T) {
+	notes := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(notes, []byte("2021-03-01,Race\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tz := range []string{"UTC", "Local", "America/Chicago"} {
+		args := []string{"-start=2021-01-01", "-end=2021-12-31", "-tz=" + tz, "-plan-start=2021-02-01", "-notes-file=" + notes}
+		a, err := parseOptions(args, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := parseOptions(args, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cacheKey(a, "svg") != cacheKey(b, "svg") {
+			t.Errorf("-tz=%s: parsing the same args twice gave different cache keys", tz)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// calendarShades are the cells for no activity then each quarter of the
+// busiest day's total.
+var calendarShades = []string{"·", "░", "▒", "▓", "█"}
+
+// calendarColors are the ANSI 256-color greens matching calendarShades.
+var calendarColors = []int{240, 22, 28, 34, 40}
+
+// writeCalendar prints a contribution-style grid of the last weeks weeks up
+// to today (or the end of the range), one row per weekday, with each day
+// shaded by its total of the metric. Color is left out when NO_COLOR is set.
+func writeCalendar(w io.Writer, activities Activities, opts Options, weeks int, now time.Time) error {
+	ref := streakRef(opts, now)
+	last := weekStart(ref, opts.weekStart)
+	first := last.AddDate(0, 0, -7*(weeks-1))
+
+	totals := map[time.Time]float64{}
+	busiest := 0.0
+	m := metrics[opts.metric]
+	for _, a := range dailyActivities(opts, activities) {
+		day := dayStart(a.Date)
+		if day.Before(first) {
+			continue
+		}
+		totals[day] += m.value(a, opts)
+		busiest = math.Max(busiest, totals[day])
+	}
+	color := os.Getenv("NO_COLOR") == ""
+
+	var b strings.Builder
+	// month labels over the first week of each month, where they fit
+	labels := []rune(strings.Repeat(" ", 2*weeks+2))
+	for i := 0; i < weeks; i++ {
+		week := first.AddDate(0, 0, 7*i)
+		if week.Day() <= 7 && (i == 0 || labels[2*i-1] == ' ') {
+			copy(labels[2*i:], []rune(week.Format("Jan")))
+		}
+	}
+	b.WriteString("    " + strings.TrimRight(string(labels), " ") + "\n")
+	for weekday := 0; weekday < 7; weekday++ {
+		b.WriteString(first.AddDate(0, 0, weekday).Format("Mon")[:2] + "  ")
+		for i := 0; i < weeks; i++ {
+			day := first.AddDate(0, 0, 7*i+weekday)
+			if day.After(ref) {
+				b.WriteString("  ")
+				continue
+			}
+			level := 0
+			if v := totals[day]; v > 0 && busiest > 0 {
+				level = 1 + int(math.Min(3, math.Floor(v/busiest*4)))
+			}
+			if color {
+				fmt.Fprintf(&b, "\x1b[38;5;%dm%s\x1b[0m ", calendarColors[level], calendarShades[level])
+			} else {
+				b.WriteString(calendarShades[level] + " ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "busiest day: %s %s\n", formatNumber(busiest, opts.precision), m.label(opts))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,747 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// series is a named group of activities drawn as its own line, such as one
+// user's activities.
+type series struct {
+	name       string
+	activities Activities
+	// color overrides the palette when set, as for per-type series
+	color drawing.Color
+	// unit overrides -unit when set, as for types listed in units.json
+	unit string
+	// ghost is a -ghost period drawn faintly behind the series after it
+	ghost bool
+}
+
+// allActivities flattens every group back into one sorted slice.
+func allActivities(groups []series) Activities {
+	var all Activities
+	for _, g := range groups {
+		all = append(all, g.activities...)
+	}
+	sort.Sort(all)
+	return all
+}
+
+// buildGraph picks the chart for the options: bars for -buckets-file, the
+// -chart kind if not a line, bars for -bucket, otherwise the line chart.
+func buildGraph(opts Options, groups []series) (graph, error) {
+	if opts.bucketsFile != "" {
+		buckets, err := readBucketsFile(opts.bucketsFile, opts.location)
+		if err != nil {
+			return nil, err
+		}
+		return buildBucketChart(opts, allActivities(groups), buckets), nil
+	}
+	if opts.chartType == "stacked-area" {
+		return buildStackedChart(opts, allActivities(groups)), nil
+	}
+	if opts.chartType == "pie" {
+		return buildPieChart(opts, allActivities(groups)), nil
+	}
+	if opts.chartType == "top-n" {
+		return buildTopChart(opts, allActivities(groups)), nil
+	}
+	if opts.chartType == "quarter-bars" {
+		return buildBucketChart(opts, allActivities(groups), periodBuckets("quarter", opts.start, opts.end, opts.weekStart)), nil
+	}
+	if opts.bucket != "" {
+		return buildBucketChart(opts, allActivities(groups), periodBuckets(opts.bucket, opts.start, opts.end, opts.weekStart)), nil
+	}
+	if opts.splitByType {
+		groups = splitByType(groups, opts.colors, opts.typeUnits)
+	}
+	return buildChart(opts, groups), nil
+}
+
+// metricValues returns the points of the selected metric, as a running total
+// when -cumulative is set. Activities without a value are skipped.
+func metricValues(opts Options, activities Activities) (xs, ys []float64) {
+	primary := metrics[opts.metric]
+	if opts.xAnchor == "end" {
+		// ordered by start, a long activity can end after a later short one,
+		// and the running total must follow the X axis
+		activities = append(Activities(nil), activities...)
+		sort.SliceStable(activities, func(i, j int) bool {
+			return plotX(opts, activities[i]) < plotX(opts, activities[j])
+		})
+	}
+	total := 0.0
+	for _, activity := range activities {
+		v := primary.value(activity, opts)
+		if v != 0 {
+			total = total + v
+			if opts.cumulative {
+				v = total
+			}
+			ys = append(ys, v)
+			xs = append(xs, plotX(opts, activity))
+		}
+	}
+	return xs, ys
+}
+
+// plotX is where an activity is plotted on the X axis: when it started, or
+// with -x-anchor end when it finished.
+func plotX(opts Options, a Activity) float64 {
+	if opts.xAnchor == "end" {
+		return float64(a.Date.Add(time.Duration(a.Duration) * time.Minute).Unix())
+	}
+	return float64(a.Date.Unix())
+}
+
+// plotValues returns the points drawn for a group by the line chart, in the
+// group's own unit and as a percentage of the goal for -as-percent.
+func plotValues(opts Options, g series) (xs, ys []float64) {
+	if g.unit != "" {
+		opts.unit = g.unit
+	}
+	xs, ys = metricValues(opts, g.activities)
+	if opts.asPercent {
+		for i := range ys {
+			ys[i] = ys[i] / opts.goals[0] * 100
+		}
+	}
+	return xs, ys
+}
+
+// buildChart turns each group of sorted activities into a line of the
+// selected metric, with a legend when there is more than one. The chart is
+// returned unrendered so callers can restyle it first; the legend reads the
+// same *chart.Chart, so changes to series show up there too.
+func buildChart(opts Options, groups []series) *chart.Chart {
+	labelOpts := opts
+	unit, shared := groupsUnit(groups, opts.unit)
+	labelOpts.unit = unit
+	yLabel := metrics[opts.metric].label(labelOpts)
+	if !shared && unitDependent(opts.metric, opts) {
+		yLabel = "Mixed units, see legend"
+	}
+	goals := append([]float64(nil), opts.goals...)
+	if opts.asPercent {
+		// percentages are of the first goal; any stretch goals scale with it
+		for i := range goals {
+			goals[i] = goals[i] / opts.goals[0] * 100
+		}
+		yLabel = "% of goal"
+	}
+	if opts.logY {
+		for i := range goals {
+			goals[i] = math.Log10(goals[i])
+		}
+	}
+	if opts.yLabel != "" {
+		yLabel = opts.yLabel
+	}
+	xLabel := "Date"
+	if !opts.planStart.IsZero() {
+		xLabel = "Day of plan"
+	}
+	if opts.session != "" {
+		xLabel = "Time"
+	}
+	if opts.xLabel != "" {
+		xLabel = opts.xLabel
+	}
+
+	var lines, ghosts, projections, trends []chart.Series
+	var annotations []chart.Value2
+	maxY := 0.0
+	minY := 0.0
+	if opts.logY {
+		minY = math.Inf(1)
+	}
+	for _, g := range groups {
+		xs, ys := plotValues(opts, g)
+		lineOpts := opts
+		if g.ghost {
+			lineOpts.project, lineOpts.trend = false, false
+		}
+		total := 0.0
+		if len(ys) > 0 {
+			total = ys[len(ys)-1]
+		}
+		projXs, projYs, projected := projectTotal(lineOpts, xs, ys, time.Now())
+		if projected {
+			if opts.logY {
+				projXs, projYs = logValues(projXs, projYs)
+			}
+			for _, y := range projYs {
+				maxY = math.Max(maxY, y)
+			}
+		}
+		trendXs, trendYs, trended := trendLine(lineOpts, xs, ys)
+		for _, y := range trendYs {
+			maxY = math.Max(maxY, y)
+		}
+		if opts.logY {
+			xs, ys = logValues(xs, ys)
+		}
+		for _, y := range ys {
+			maxY = math.Max(maxY, y)
+			minY = math.Min(minY, y)
+		}
+		points := len(xs)
+		// every is how many plotted points there are per activity
+		every := 1
+		switch {
+		case opts.step:
+			xs, ys = stepPoints(xs, ys)
+			every = 2
+		case opts.smooth:
+			xs, ys = smoothPoints(xs, ys)
+			every = smoothSteps
+		}
+		style := seriesStyle(opts)
+		if opts.markers && opts.style == "line" && !g.ghost {
+			addMarkers(&style, points, every)
+		}
+		color := g.color
+		if color.IsZero() {
+			// pinned rather than left to go-chart, which colors by position and
+			// so would shift when -shade-weekends adds a series in front
+			color = chart.GetDefaultColor(len(lines))
+		}
+		style.StrokeColor, style.DotColor = color, color
+		if g.ghost {
+			// an empty previous period draws nothing, not even a legend entry
+			if len(xs) > 0 {
+				style.StrokeColor, style.DotColor = color.WithAlpha(64), color.WithAlpha(64)
+				ghosts = append(ghosts, chart.ContinuousSeries{Name: g.name, Style: style, XValues: xs, YValues: ys})
+			}
+			continue
+		}
+		lines = append(lines, chart.ContinuousSeries{
+			Name:    g.name,
+			Style:   style,
+			XValues: xs,
+			YValues: ys,
+		})
+		if opts.annotateDescriptions {
+			annotations = append(annotations, descriptionAnnotations(opts, g.activities, xs, ys, color)...)
+		}
+		if projected {
+			name := "Projected"
+			if g.name != "" {
+				name = g.name + " projected"
+			}
+			projections = append(projections, chart.ContinuousSeries{
+				Name:    name,
+				Style:   chart.Style{StrokeColor: color, StrokeDashArray: []float64{6, 4}},
+				XValues: projXs,
+				YValues: projYs,
+			})
+		}
+		if trended {
+			name := "Trend"
+			if g.name != "" {
+				name = g.name + " trend"
+			}
+			trends = append(trends, chart.ContinuousSeries{
+				Name:    name,
+				Style:   chart.Style{StrokeColor: color, StrokeDashArray: []float64{2, 3}},
+				XValues: trendXs,
+				YValues: trendYs,
+			})
+		}
+		if opts.annotateTotal && len(ys) > 0 {
+			annotations = append(annotations, chart.Value2{
+				XValue: xs[len(xs)-1],
+				YValue: ys[len(ys)-1],
+				Label:  totalLabel(opts, g, total),
+				Style:  chart.Style{StrokeColor: color, FontSize: 12},
+			})
+		}
+	}
+	// after the loop, which pins line colors by how many lines came before;
+	// ghosts go first so they draw behind
+	lines = append(ghosts, lines...)
+	lines = append(lines, projections...)
+	lines = append(lines, trends...)
+	// keep every goal line on the chart even when we're well short of it
+	for _, goal := range goals {
+		maxY = math.Max(maxY, goal)
+		minY = math.Min(minY, goal)
+	}
+	if math.IsInf(minY, 1) {
+		minY = 0
+	}
+
+	// The secondary series keeps its own X values, so it doesn't matter if it
+	// has many more (or fewer) points than the primary series.
+	var ys2 []float64
+	var xs2 []float64
+	max2 := 0.0
+
+	if opts.secondaryMetric != "" {
+		secondary := metrics[opts.secondaryMetric]
+		for _, activity := range allActivities(withoutGhosts(groups)) {
+			v := secondary.value(activity, opts)
+			if v != 0 {
+				ys2 = append(ys2, v)
+				xs2 = append(xs2, plotX(opts, activity))
+				max2 = math.Max(max2, v)
+			}
+		}
+	}
+
+	ticks := yTicks(maxY, opts.yTickCount, opts.precision)
+	if !opts.logY {
+		// the axis runs up to the tick above the data, so notes reach it too
+		maxY = ticks[len(ticks)-1].Value
+	}
+	first := time.Date(opts.start.Year(), opts.start.Month(), 1, 0, 0, 0, 0, opts.location)
+	graph := &chart.Chart{
+		YAxis: chart.YAxis{
+			Name:  yLabel,
+			Ticks: ticks,
+			Range: &chart.ContinuousRange{Min: 0, Max: maxY},
+		},
+		XAxis: chart.XAxis{
+			Name:  xLabel,
+			Ticks: monthTicks(first, monthsBetween(first, opts.end)+1, opts.monthStep),
+		},
+		Series: lines,
+	}
+	if !opts.planStart.IsZero() {
+		graph.XAxis.Ticks = planTicks(opts.planStart, opts.start, opts.end)
+	}
+	if all := allActivities(groups); opts.session != "" && len(all) > 0 {
+		last := all[len(all)-1]
+		graph.XAxis.Ticks = sessionTicks(all[0].Date, last.Date.Add(time.Duration(last.Duration)*time.Minute))
+	}
+
+	if opts.logY {
+		minY, maxY = math.Floor(minY), math.Ceil(maxY)
+		if maxY <= minY {
+			maxY = minY + 1
+		}
+		graph.YAxis.Ticks = logTicks(minY, maxY, opts.precision)
+		graph.YAxis.Range = &chart.ContinuousRange{Min: minY, Max: maxY}
+	}
+	if opts.shadeWeekends {
+		graph.Series = append([]chart.Series{weekendShade{start: opts.start, end: opts.end}}, graph.Series...)
+	}
+	if opts.highlightStreak {
+		_, current := dailyStreaks(allActivities(withoutGhosts(groups)), streakRef(opts, time.Now()))
+		if current.length > 0 {
+			graph.Series = append([]chart.Series{spanShade{
+				name:  fmt.Sprintf("Current streak (%d days)", current.length),
+				from:  current.start,
+				to:    current.last.AddDate(0, 0, 1),
+				style: streakStyle,
+			}}, graph.Series...)
+		}
+	}
+	addGoalLines(graph, goals, opts.goals, float64(opts.start.Unix()), float64(opts.end.Unix()), opts.precision)
+	addNotes(graph, opts.notes, opts.start, opts.end, minY, maxY)
+
+	if opts.secondaryMetric != "" {
+		if opts.logY {
+			// the same log scale as the primary axis, so the two agree
+			xs2, ys2 = logValues(xs2, ys2)
+		}
+		if len(ys2) == 0 {
+			infof("warning: no %s data found, skipping secondary axis\n", opts.secondaryMetric)
+		} else {
+			graph.YAxisSecondary = chart.YAxis{
+				Name:  metrics[opts.secondaryMetric].label(opts),
+				Range: &chart.ContinuousRange{Min: 0, Max: max2 * 1.1},
+			}
+			if opts.logY {
+				min2, max2 := math.Floor(slices.Min(ys2)), math.Ceil(slices.Max(ys2))
+				if max2 <= min2 {
+					max2 = min2 + 1
+				}
+				graph.YAxisSecondary.Ticks = logTicks(min2, max2, opts.precision)
+				graph.YAxisSecondary.Range = &chart.ContinuousRange{Min: min2, Max: max2}
+			}
+			graph.Series = append(graph.Series, chart.ContinuousSeries{
+				Name:    metrics[opts.secondaryMetric].label(opts),
+				YAxis:   chart.YAxisSecondary,
+				XValues: xs2,
+				YValues: ys2,
+			})
+		}
+	}
+	if len(annotations) > 0 {
+		graph.Series = append(graph.Series, chart.AnnotationSeries{Annotations: annotations})
+	}
+	applyLayout(opts, graph)
+	if len(groups) > 1 {
+		graph.Elements = []chart.Renderable{chart.Legend(graph)}
+	}
+	if opts.annotateActiveDays {
+		graph.Elements = append(graph.Elements, activeDaysElement(opts, allActivities(withoutGhosts(groups)), &graph.Background.Padding))
+	}
+	return graph
+}
+
+// totalLabel formats a cumulative line's final value for -annotate-total,
+// such as "1,842 mi".
+func totalLabel(opts Options, g series, total float64) string {
+	label := formatThousands(total, opts.precision)
+	switch {
+	case opts.asPercent:
+		return label + "%"
+	case opts.metric == "distance":
+		label = groupThousands(formatDistance(total, opts))
+		unit := opts.unit
+		if g.unit != "" {
+			unit = g.unit
+		}
+		return label + " " + unit
+	}
+	return label + " " + strings.ToLower(metrics[opts.metric].label(opts))
+}
+
+// descriptionAnnotations labels each plotted activity that has a description
+// with it, at the activity's point in xs and ys, outlined in the line's color.
+func descriptionAnnotations(opts Options, activities Activities, xs, ys []float64, color drawing.Color) []chart.Value2 {
+	// the last point at an X is where a step line lands
+	plotted := map[float64]float64{}
+	for i, x := range xs {
+		plotted[x] = ys[i]
+	}
+	var annotations []chart.Value2
+	for _, a := range activities {
+		x := plotX(opts, a)
+		y, ok := plotted[x]
+		if a.Description == "" || !ok {
+			continue
+		}
+		label := strings.TrimSpace(a.Description)
+		if r := []rune(label); len(r) > 40 {
+			label = string(r[:39]) + "…"
+		}
+		annotations = append(annotations, chart.Value2{XValue: x, YValue: y, Label: label, Style: chart.Style{StrokeColor: color}})
+	}
+	return annotations
+}
+
+// groupsUnit returns the unit every group is charted in, defaulting to def,
+// and false when they differ.
+func groupsUnit(groups []series, def string) (string, bool) {
+	unit := ""
+	for _, g := range groups {
+		u := def
+		if g.unit != "" {
+			u = g.unit
+		}
+		if unit != "" && u != unit {
+			return def, false
+		}
+		unit = u
+	}
+	if unit == "" {
+		unit = def
+	}
+	return unit, true
+}
+
+// unitDependent reports whether the metric's label, and so its values, change
+// with -unit.
+func unitDependent(metric string, opts Options) bool {
+	mi, km := opts, opts
+	mi.unit, km.unit = "mi", "km"
+	return metrics[metric].label(mi) != metrics[metric].label(km)
+}
+
+// stepPoints adds a point before each jump carrying the previous total, so a
+// cumulative line stays flat between activities instead of sloping across
+// days with none.
+func stepPoints(xs, ys []float64) ([]float64, []float64) {
+	if len(xs) < 2 {
+		return xs, ys
+	}
+	stepXs := []float64{xs[0]}
+	stepYs := []float64{ys[0]}
+	for i := 1; i < len(xs); i++ {
+		stepXs = append(stepXs, xs[i], xs[i])
+		stepYs = append(stepYs, ys[i-1], ys[i])
+	}
+	return stepXs, stepYs
+}
+
+// projectTotal extrapolates the cumulative line xs, ys from its total at now,
+// at the same average pace, to the end of the range for -project, returning
+// the dashed segment between the two. There is nothing to project before the
+// range starts, after it ends or without -project.
+func projectTotal(opts Options, xs, ys []float64, now time.Time) (projXs, projYs []float64, ok bool) {
+	if !opts.project || !now.After(opts.start) || !now.Before(opts.end) {
+		return nil, nil, false
+	}
+	total := 0.0
+	for i, x := range xs {
+		if x > float64(now.Unix()) {
+			break
+		}
+		total = ys[i]
+	}
+	elapsed := now.Sub(opts.start).Seconds()
+	projected := total / elapsed * opts.end.Sub(opts.start).Seconds()
+	return []float64{float64(now.Unix()), float64(opts.end.Unix())}, []float64{total, projected}, true
+}
+
+// trendLine is the least-squares fit through the points for -trend, drawn
+// from the first activity to the last.
+func trendLine(opts Options, xs, ys []float64) (trendXs, trendYs []float64, ok bool) {
+	if !opts.trend {
+		return nil, nil, false
+	}
+	slope, intercept, ok := linearFit(xs, ys)
+	if !ok {
+		return nil, nil, false
+	}
+	first, last := xs[0], xs[len(xs)-1]
+	return []float64{first, last}, []float64{intercept + slope*first, intercept + slope*last}, true
+}
+
+// linearFit returns the slope and intercept of the least-squares line
+// through the points. It fails with fewer than two distinct X values.
+func linearFit(xs, ys []float64) (slope, intercept float64, ok bool) {
+	n := float64(len(xs))
+	if n < 2 {
+		return 0, 0, false
+	}
+	// centered on the means, since Unix seconds squared lose precision
+	meanX, meanY := 0.0, 0.0
+	for i := range xs {
+		meanX += xs[i] / n
+		meanY += ys[i] / n
+	}
+	sxx, sxy := 0.0, 0.0
+	for i := range xs {
+		dx := xs[i] - meanX
+		sxx += dx * dx
+		sxy += dx * (ys[i] - meanY)
+	}
+	if sxx == 0 {
+		return 0, 0, false
+	}
+	slope = sxy / sxx
+	return slope, meanY - slope*meanX, true
+}
+
+// smoothSteps is how many points smoothPoints draws per activity.
+const smoothSteps = 8
+
+// smoothPoints interpolates a curve through the points for -smooth with a
+// monotone cubic spline, which unlike a plain spline never overshoots, so a
+// cumulative line still never dips. Points at the same time are kept as is.
+func smoothPoints(xs, ys []float64) ([]float64, []float64) {
+	n := len(xs)
+	if n < 3 {
+		return xs, ys
+	}
+	// secant slopes, then Fritsch-Carlson tangents
+	d := make([]float64, n-1)
+	for k := range d {
+		if h := xs[k+1] - xs[k]; h > 0 {
+			d[k] = (ys[k+1] - ys[k]) / h
+		}
+	}
+	m := make([]float64, n)
+	m[0], m[n-1] = d[0], d[n-2]
+	for k := 1; k < n-1; k++ {
+		if d[k-1]*d[k] > 0 {
+			m[k] = (d[k-1] + d[k]) / 2
+		}
+	}
+	for k, dk := range d {
+		if dk == 0 {
+			m[k], m[k+1] = 0, 0
+			continue
+		}
+		a, b := m[k]/dk, m[k+1]/dk
+		if r := a*a + b*b; r > 9 {
+			t := 3 / math.Sqrt(r)
+			m[k], m[k+1] = t*a*dk, t*b*dk
+		}
+	}
+
+	smoothXs := []float64{xs[0]}
+	smoothYs := []float64{ys[0]}
+	for k := 0; k < n-1; k++ {
+		h := xs[k+1] - xs[k]
+		for i := 1; i <= smoothSteps; i++ {
+			t := float64(i) / smoothSteps
+			t2, t3 := t*t, t*t*t
+			smoothXs = append(smoothXs, xs[k]+t*h)
+			smoothYs = append(smoothYs, (2*t3-3*t2+1)*ys[k]+(t3-2*t2+t)*h*m[k]+(-2*t3+3*t2)*ys[k+1]+(t3-t2)*h*m[k+1])
+		}
+	}
+	return smoothXs, smoothYs
+}
+
+// seriesStyle is the style of each metric line: the go-chart default, or
+// dots with no connecting stroke for -style scatter.
+func seriesStyle(opts Options) chart.Style {
+	if opts.style == "scatter" {
+		return chart.Style{StrokeWidth: chart.Disabled, DotWidth: 3}
+	}
+	return chart.Style{}
+}
+
+// addMarkers puts a dot on every activity of a line for -markers, which is
+// every point when each is plotted once. Dots shrink as the count of points
+// grows, and are left off entirely past maxMarkers, where they would merge
+// into a thicker line anyway.
+func addMarkers(style *chart.Style, points, every int) {
+	if points > maxMarkers {
+		infof("%d activities is too many for -markers, drawing the line without them\n", points)
+		return
+	}
+	width := math.Max(1, math.Min(3, 300/float64(points)))
+	style.DotWidth = width
+	if every > 1 {
+		// the points stepPoints and smoothPoints add between activities
+		style.DotWidthProvider = func(_, _ chart.Range, i int, _, _ float64) float64 {
+			if i%every != 0 {
+				return 0
+			}
+			return width
+		}
+	}
+}
+
+// maxMarkers is the most activities -markers will dot.
+const maxMarkers = 1000
+
+// goalStyles are cycled through so each goal line is distinguishable.
+var goalStyles = []chart.Style{
+	{StrokeColor: drawing.ColorRed, StrokeDashArray: []float64{5, 5}},
+	{StrokeColor: drawing.Color{R: 255, G: 140, A: 255}, StrokeDashArray: []float64{10, 5}},
+	{StrokeColor: drawing.Color{R: 128, B: 128, A: 255}, StrokeDashArray: []float64{2, 4}},
+}
+
+// addGoalLines draws a horizontal line from minX to maxX at each of ys,
+// labelled at its right end with the matching target from labels.
+func addGoalLines(graph *chart.Chart, ys, labels []float64, minX, maxX float64, precision int) {
+	var annotations []chart.Value2
+	for i, y := range ys {
+		style := goalStyles[i%len(goalStyles)]
+		graph.Series = append(graph.Series, chart.ContinuousSeries{
+			Name:    "Goal " + formatNumber(labels[i], precision),
+			Style:   style,
+			XValues: []float64{minX, maxX},
+			YValues: []float64{y, y},
+		})
+		annotations = append(annotations, chart.Value2{
+			XValue: maxX,
+			YValue: y,
+			Label:  formatNumber(labels[i], precision),
+			Style:  chart.Style{StrokeColor: style.StrokeColor},
+		})
+	}
+	if len(annotations) > 0 {
+		graph.Series = append(graph.Series, chart.AnnotationSeries{Annotations: annotations})
+	}
+}
+
+// monthTicks returns a tick at the start of every step-th of the months after
+// start, plus the month it ends on. Values and labels both come from start's
+// location so a chart renders the same whatever the host's TZ.
+func monthTicks(start time.Time, months, step int) []chart.Tick {
+	var ticks []chart.Tick
+	for i := 0; i <= months; i += step {
+		t := start.AddDate(0, i, 0)
+		ticks = append(ticks, chart.Tick{Value: float64(t.Unix()), Label: t.Format("2006-01")})
+	}
+	return ticks
+}
+
+// planTicks labels the days of a plan starting on day 1 at planStart, at day
+// 1 and then every week or, for long ranges, every few weeks, "Day 7", "Day
+// 14" and so on. Only the days between start and end get a tick.
+func planTicks(planStart, start, end time.Time) []chart.Tick {
+	days := int(end.Sub(planStart).Hours()/24) + 1
+	step := 7 * ((days + 7*15 - 1) / (7 * 15))
+	if step == 0 {
+		step = 7
+	}
+	var ticks []chart.Tick
+	for day := 1; day <= days; day = (day/step + 1) * step {
+		// AddDate, not a fixed 24h, so DST changes don't shift the ticks
+		t := planStart.AddDate(0, 0, day-1)
+		if !t.Before(start) {
+			ticks = append(ticks, chart.Tick{Value: float64(t.Unix()), Label: "Day " + strconv.Itoa(day)})
+		}
+	}
+	return ticks
+}
+
+// monthsBetween counts the calendar months from start's month to end's.
+func monthsBetween(start, end time.Time) int {
+	return (end.Year()-start.Year())*12 + int(end.Month()-start.Month())
+}
+
+// yTicks returns ticks from zero to the first at or above max, about count
+// of them, at a "nice" step of 1, 2, 2.5 or 5 times a power of ten so labels
+// read 250, 500, 750 rather than 333.33, 666.67.
+func yTicks(max float64, count, precision int) []chart.Tick {
+	if max <= 0 {
+		max = 1
+	}
+	step := niceStep(max / float64(count))
+	top := math.Ceil(max/step-1e-9) * step
+	var ticks []chart.Tick
+	for i := 0; float64(i)*step <= top+step/2; i++ {
+		v := float64(i) * step
+		ticks = append(ticks, chart.Tick{Value: v, Label: formatNumber(v, precision)})
+	}
+	return ticks
+}
+
+// niceStep rounds raw up to the nearest 1, 2, 2.5 or 5 times a power of ten.
+func niceStep(raw float64) float64 {
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, f := range []float64{1, 2, 2.5, 5} {
+		if raw <= f*magnitude*(1+1e-9) {
+			return f * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// logValues drops the points -log-y can't draw, zero or below, and returns
+// the rest with Y as its base 10 logarithm.
+func logValues(xs, ys []float64) ([]float64, []float64) {
+	var logXs, logYs []float64
+	for i, y := range ys {
+		if y > 0 {
+			logXs = append(logXs, xs[i])
+			logYs = append(logYs, math.Log10(y))
+		}
+	}
+	return logXs, logYs
+}
+
+// logTicks returns ticks at 1, 2 and 5 times each power of ten between the
+// log10 values min and max, labelled with the unlogged value.
+func logTicks(min, max float64, precision int) []chart.Tick {
+	var ticks []chart.Tick
+	for exp := min; exp <= max; exp++ {
+		for _, m := range []float64{1, 2, 5} {
+			v := exp + math.Log10(m)
+			if v > max {
+				break
+			}
+			ticks = append(ticks, chart.Tick{Value: v, Label: formatNumber(math.Pow(10, v), precision)})
+		}
+	}
+	return ticks
+}
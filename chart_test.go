@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wcharczuk/go-chart"
+)
+
+func TestMetricValuesEndAnchorInOrder(t *testing.T) {
+	opts := Options{metric: "distance", unit: "mi", cumulative: true, xAnchor: "end"}
+	morning := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	activities := Activities{
+		{Date: morning, Duration: 180, Distance: 30},
+		{Date: morning.Add(time.Hour), Duration: 30, Distance: 5},
+	}
+	xs, ys := metricValues(opts, activities)
+	want := []float64{float64(morning.Add(90 * time.Minute).Unix()), float64(morning.Add(3 * time.Hour).Unix())}
+	if len(xs) != 2 || xs[0] != want[0] || xs[1] != want[1] {
+		t.Fatalf("xs = %v, want %v", xs, want)
+	}
+	if ys[0] != convertDistance(5, "mi") || ys[1] != convertDistance(35, "mi") {
+		t.Errorf("ys = %v, want the running total in end order", ys)
+	}
+}
+
+func TestAxisLabelsIgnoreHostTZ(t *testing.T) {
+	local := time.Local
+	t.Cleanup(func() { time.Local = local })
+
+	// midnight on Jan 1 in Tokyo is still December in UTC and westward
+	opts, err := parseOptions([]string{"-start=2021-01-01", "-end=2021-12-31", "-tz=Asia/Tokyo"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var activities Activities
+	for i := 0; i < 24; i++ {
+		activities = append(activities, Activity{Date: opts.start.AddDate(0, 0, 15*i), ActivityType: 1, Duration: 45, Distance: 10})
+	}
+	labels := func(host string) []string {
+		loc, err := time.LoadLocation(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Local = loc
+		var labels []string
+		for _, tick := range buildChart(opts, []series{{activities: activities}}).XAxis.Ticks {
+			labels = append(labels, tick.Label)
+		}
+		return labels
+	}
+	want := labels("UTC")
+	if len(want) == 0 || want[0] != "2021-01" {
+		t.Fatalf("got X axis labels %v, want them to start at 2021-01", want)
+	}
+	for _, host := range []string{"America/Los_Angeles", "Pacific/Kiritimati"} {
+		if got := labels(host); strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("TZ=%s: got X axis labels %v, want %v as under UTC", host, got, want)
+		}
+	}
+}
+
+func TestSecondaryAxisLogY(t *testing.T) {
+	opts, err := parseOptions([]string{"-start=2021-01-01", "-end=2021-12-31", "-tz=UTC", "-log-y", "-secondary-metric=heart-rate"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var activities Activities
+	for i := 0; i < 10; i++ {
+		activities = append(activities, Activity{Date: opts.start.AddDate(0, 0, 7*i), ActivityType: 1, Duration: 45, Distance: 10, HeartRate: 100 + float64(10*i), Has: hasHeartRate})
+	}
+	graph := buildChart(opts, []series{{activities: activities}})
+	var secondary chart.ContinuousSeries
+	for _, s := range graph.Series {
+		if c, ok := s.(chart.ContinuousSeries); ok && c.YAxis == chart.YAxisSecondary {
+			secondary = c
+		}
+	}
+	if len(secondary.YValues) != len(activities) {
+		t.Fatalf("got %d secondary points, want %d", len(secondary.YValues), len(activities))
+	}
+	for i, y := range secondary.YValues {
+		if want := math.Log10(activities[i].HeartRate); math.Abs(y-want) > 1e-9 {
+			t.Errorf("point %d: got %v, want log10 of %v", i, y, activities[i].HeartRate)
+		}
+	}
+	r := graph.YAxisSecondary.Range
+	if r.GetMin() != 2 || r.GetMax() != 3 || len(graph.YAxisSecondary.Ticks) == 0 {
+		t.Errorf("got secondary range %v to %v with %d ticks, want a log scale from 2 to 3", r.GetMin(), r.GetMax(), len(graph.YAxisSecondary.Ticks))
+	}
+	if err := render(graph, "svg", "", io.Discard); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsPercentRejectsSecondaryMetric(t *testing.T) {
+	_, err := parseOptions([]string{"-start=2021-01-01", "-end=2021-12-31", "-tz=UTC", "-goal=1000", "-as-percent", "-secondary-metric=heart-rate"}, io.Discard)
+	if err == nil {
+		t.Error("-as-percent with -secondary-metric was accepted")
+	}
+}
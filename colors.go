@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// readColorsFile parses "type,#rrggbb" lines, where type is an activity type
+// ID or name such as "running". Blank lines and lines starting with # are
+// skipped.
+func readColorsFile(path string) (map[int64]drawing.Color, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	colors := map[int64]drawing.Color{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, hex, ok := strings.Cut(text, ",")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected type,color", path, line)
+		}
+		id, ok := lookupActivityType(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: unknown activity type %q", path, line, name)
+		}
+		hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+		if _, err := strconv.ParseUint(hex, 16, 32); err != nil || len(hex) != 6 {
+			return nil, fmt.Errorf("%s:%d: invalid color %q", path, line, hex)
+		}
+		colors[id] = drawing.ColorFromHex(hex)
+	}
+	return colors, scanner.Err()
+}
+
+// lookupActivityType resolves an activity type ID or a case-insensitive name.
+func lookupActivityType(s string) (int64, bool) {
+	if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return id, true
+	}
+	for id, name := range activityTypes {
+		if strings.EqualFold(name, s) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// typePalette is picked from by type ID. go-chart's own palette has only five
+// colors, which puts biking (1) and road biking (16) on the same one.
+var typePalette = []drawing.Color{
+	chart.ColorBlue,
+	chart.ColorRed,
+	chart.ColorGreen,
+	chart.ColorOrange,
+	chart.ColorCyan,
+	drawing.ColorFromHex("b10dc9"),
+	drawing.ColorFromHex("85144b"),
+	drawing.ColorFromHex("3d9970"),
+	drawing.ColorFromHex("ff4136"),
+	drawing.ColorFromHex("001f3f"),
+	drawing.ColorFromHex("f012be"),
+	drawing.ColorFromHex("aaaaaa"),
+}
+
+// typeColor is the color for an activity type: from -colors-file, or else
+// picked from typePalette by ID so it is the same every run.
+func typeColor(colors map[int64]drawing.Color, id int64) drawing.Color {
+	if c, ok := colors[id]; ok {
+		return c
+	}
+	return typePalette[int(id)%len(typePalette)]
+}
+
+// splitByType breaks each group into one series per activity type, colored
+// with typeColor. Types listed in typeUnits are charted in their own unit,
+// which is added to the series name.
+func splitByType(groups []series, colors map[int64]drawing.Color, typeUnits map[int64]string) []series {
+	var split []series
+	for _, g := range groups {
+		byType := map[int64]Activities{}
+		for _, a := range g.activities {
+			byType[a.ActivityType] = append(byType[a.ActivityType], a)
+		}
+		var ids []int64
+		for id := range byType {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			name := activityTypeName(id)
+			if g.name != "" {
+				name = g.name + " " + name
+			}
+			unit := typeUnits[id]
+			if unit != "" {
+				name += " (" + units[unit].distance + ")"
+			}
+			split = append(split, series{name: name, activities: byType[id], color: typeColor(colors, id), unit: unit})
+		}
+	}
+	return split
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openExport opens path for an export, treating "-" as stdout. The returned
+// close func is a no-op for stdout.
+func openExport(path string) (io.Writer, func() error, error) {
+	if path == "-" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// writeExport runs write against the export at path.
+func writeExport(path string, write func(io.Writer) error) error {
+	w, closeFn, err := openExport(path)
+	if err != nil {
+		return err
+	}
+	if err := write(w); err != nil {
+		closeFn()
+		return err
+	}
+	return closeFn()
+}
+
+// writeExports writes every export requested in opts. It reports whether any
+// of them went to stdout, in which case the chart must not.
+func writeExports(opts Options, groups []series) (bool, error) {
+	if opts.influx != "" {
+		err := writeExport(opts.influx, func(w io.Writer) error {
+			return writeInflux(w, groups, opts)
+		})
+		if err != nil {
+			return false, fmt.Errorf("error writing influx export: %v", err)
+		}
+	}
+	if opts.prom != "" {
+		write := func(w io.Writer) error {
+			return writeProm(w, computeStats(allActivities(groups), opts.unit), opts.unit)
+		}
+		var err error
+		if opts.prom == "-" {
+			err = write(os.Stdout)
+		} else {
+			// node_exporter may read the file at any time, so never expose a partial one
+			err = writeFileAtomic(opts.prom, write)
+		}
+		if err != nil {
+			return false, fmt.Errorf("error writing prometheus textfile: %v", err)
+		}
+	}
+	if opts.tsv != "" {
+		err := writeExport(opts.tsv, func(w io.Writer) error {
+			return writeTSV(w, groups, opts)
+		})
+		if err != nil {
+			return false, fmt.Errorf("error writing TSV: %v", err)
+		}
+	}
+	if opts.csv != "" {
+		err := writeExport(opts.csv, func(w io.Writer) error {
+			return writeCSV(w, allActivities(groups), opts)
+		})
+		if err != nil {
+			return false, fmt.Errorf("error writing CSV: %v", err)
+		}
+	}
+	if opts.json != "" {
+		err := writeExport(opts.json, func(w io.Writer) error {
+			return writeJSON(w, allActivities(groups))
+		})
+		if err != nil {
+			return false, fmt.Errorf("error writing JSON: %v", err)
+		}
+	}
+	if opts.ics != "" {
+		err := writeExport(opts.ics, func(w io.Writer) error {
+			return writeICS(w, allActivities(groups), opts, time.Now())
+		})
+		if err != nil {
+			return false, fmt.Errorf("error writing ICS: %v", err)
+		}
+	}
+	if opts.table {
+		if err := writeTable(os.Stdout, allActivities(groups), opts); err != nil {
+			return false, fmt.Errorf("error writing table: %v", err)
+		}
+	}
+	if opts.calendar > 0 {
+		if err := writeCalendar(os.Stdout, allActivities(groups), opts, opts.calendar, time.Now()); err != nil {
+			return false, fmt.Errorf("error writing calendar: %v", err)
+		}
+	}
+	if opts.stats {
+		if err := writeStats(os.Stdout, allActivities(groups), opts, time.Now()); err != nil {
+			return false, fmt.Errorf("error writing stats: %v", err)
+		}
+	}
+	return opts.influx == "-" || opts.prom == "-" || opts.tsv == "-" || opts.json == "-" || opts.csv == "-" || opts.ics == "-" || opts.table || opts.stats || opts.calendar > 0, nil
+}
+
+// writeTSV writes the points the line chart plots, after the cumulative and
+// percentage transforms, as series, date and value columns.
+func writeTSV(w io.Writer, groups []series, opts Options) error {
+	if opts.splitByType {
+		groups = splitByType(groups, opts.colors, opts.typeUnits)
+	}
+	if _, err := fmt.Fprintf(w, "series\tdate\t%s\n", metrics[opts.metric].label(opts)); err != nil {
+		return err
+	}
+	for _, g := range groups {
+		xs, ys := plotValues(opts, g)
+		for i := range xs {
+			date := time.Unix(int64(xs[i]), 0).In(opts.location).Format("2006-01-02 15:04:05")
+			if _, err := fmt.Fprintf(w, "%s\t%s\t%g\n", g.name, date, ys[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeCSV writes one row per activity in the -sort order with every value
+// Fit reported. Values it had no data for are blank rather than zero.
+func writeCSV(w io.Writer, activities Activities, opts Options) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"date", "type", "name", "duration_minutes", "distance_" + promUnits[opts.unit],
+		"calories", "steps", "heart_rate", "active_minutes", "elevation_feet"})
+	for _, a := range textOrder(activities, opts) {
+		optional := func(has fields, v string) string {
+			if a.Has&has == 0 {
+				return ""
+			}
+			return v
+		}
+		cw.Write([]string{
+			a.Date.In(opts.location).Format(time.RFC3339),
+			activityTypeName(a.ActivityType),
+			a.Name,
+			strconv.FormatInt(a.Duration, 10),
+			strconv.FormatFloat(roundDistance(convertDistance(a.Distance, opts.unit), opts), 'f', -1, 64),
+			optional(hasCalories, strconv.FormatFloat(a.Calories, 'f', -1, 64)),
+			optional(hasSteps, strconv.FormatInt(a.Steps, 10)),
+			optional(hasHeartRate, strconv.FormatFloat(a.HeartRate, 'f', -1, 64)),
+			optional(hasActiveMinutes, strconv.FormatInt(a.ActiveMinutes, 10)),
+			optional(hasElevation, strconv.FormatFloat(a.Elevation, 'f', -1, 64)),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var influxTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// writeInflux writes one line-protocol point per activity in the activity
+// measurement, tagged by type, name and user.
+func writeInflux(w io.Writer, groups []series, opts Options) error {
+	for _, g := range groups {
+		for _, a := range g.activities {
+			tags := "activity,type=" + influxTagEscaper.Replace(activityTypeName(a.ActivityType))
+			if a.Name != "" {
+				tags += ",name=" + influxTagEscaper.Replace(a.Name)
+			}
+			if g.name != "" {
+				tags += ",user=" + influxTagEscaper.Replace(g.name)
+			}
+			_, err := fmt.Fprintf(w, "%s distance=%g,duration=%di %d\n", tags, roundDistance(convertDistance(a.Distance, opts.unit), opts), a.Duration, a.Date.UnixNano())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeProm writes the stats in the Prometheus text exposition format for the
+// node_exporter textfile collector.
+func writeProm(w io.Writer, s stats, unit string) error {
+	distance := "fitgraph_total_distance_" + promUnits[unit]
+	typeDistance := "fitgraph_type_distance_" + promUnits[unit]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s Total distance of all charted activities.\n", distance)
+	fmt.Fprintf(&b, "# TYPE %s gauge\n", distance)
+	fmt.Fprintf(&b, "%s %g\n", distance, s.totalDistance)
+	b.WriteString("# HELP fitgraph_total_duration_minutes Total duration of all charted activities.\n")
+	b.WriteString("# TYPE fitgraph_total_duration_minutes gauge\n")
+	fmt.Fprintf(&b, "fitgraph_total_duration_minutes %d\n", s.totalDuration)
+	b.WriteString("# HELP fitgraph_activities_total Number of activities by type.\n")
+	b.WriteString("# TYPE fitgraph_activities_total counter\n")
+	for _, id := range s.types() {
+		fmt.Fprintf(&b, "fitgraph_activities_total{type=\"%s\"} %d\n", promLabelEscaper.Replace(activityTypeName(id)), s.byType[id].count)
+	}
+	fmt.Fprintf(&b, "# HELP %s Distance by activity type.\n", typeDistance)
+	fmt.Fprintf(&b, "# TYPE %s gauge\n", typeDistance)
+	for _, id := range s.types() {
+		fmt.Fprintf(&b, "%s{type=\"%s\"} %g\n", typeDistance, promLabelEscaper.Replace(activityTypeName(id)), s.byType[id].distance)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// promUnits are the metric name suffixes for each -unit.
+var promUnits = map[string]string{
+	"mi": "miles",
+	"km": "kilometers",
+	"m":  "meters",
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportDistanceRoundsInEveryMode(t *testing.T) {
+	activities := Activities{{Date: time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC), ActivityType: 8, Duration: 30, Distance: 3.14159}}
+	for mode, want := range map[string]string{"nearest": "3.14", "floor": "3.14", "ceil": "3.15"} {
+		opts := Options{location: time.UTC, unit: "mi", precision: 2, roundMode: mode}
+
+		var b strings.Builder
+		if err := writeCSV(&b, activities, opts); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(strings.NewReader(b.String())).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rows[1][4]; got != want {
+			t.Errorf("-round-mode %s: CSV distance %s, want %s", mode, got, want)
+		}
+
+		b.Reset()
+		if err := writeInflux(&b, []series{{activities: activities}}, opts); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(b.String(), "distance="+want+",") {
+			t.Errorf("-round-mode %s: influx line %q, want distance=%s", mode, b.String(), want)
+		}
+	}
+}
+
+func TestWriteCSVSortOrder(t *testing.T) {
+	first := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	activities := Activities{{Date: first, Name: "first"}, {Date: first.AddDate(0, 0, 1), Name: "second"}}
+	for order, want := range map[string][]string{"asc": {"first", "second"}, "desc": {"second", "first"}} {
+		var b strings.Builder
+		if err := writeCSV(&b, activities, Options{location: time.UTC, unit: "mi", sortOrder: order}); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(strings.NewReader(b.String())).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := []string{rows[1][2], rows[2][2]}; got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("-sort %s: got rows %v, want %v", order, got, want)
+		}
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/api/fitness/v1"
+	"google.golang.org/api/option"
+)
+
+// fetchActivities lists userID's sessions in the query range and aggregates
+// each one into an Activity. userID is "me" unless impersonating via
+// domain-wide delegation.
+func fetchActivities(opts Options, client *http.Client, userID string) (Activities, error) {
+	fitnessService, err := fitness.NewService(context.TODO(), option.WithHTTPClient(client))
+	if err != nil {
+		return nil, err
+	}
+	// option.WithUserAgent is dropped when the client comes from
+	// WithHTTPClient, so set the fragment on the service itself
+	fitnessService.UserAgent = opts.userAgent
+
+	datasetService := fitness.NewUsersDatasetService(fitnessService)
+	dataSourcesDatasetsService := fitness.NewUsersDataSourcesDatasetsService(fitnessService)
+	sessionService := fitness.NewUsersSessionsService(fitnessService)
+	if opts.noSessions {
+		return fetchDailyDistance(opts, dataSourcesDatasetsService, userID)
+	}
+
+	var sessions []*fitness.Session
+	seen := map[string]bool{}
+	for _, r := range chunkRanges(opts.start, opts.end, opts.chunk) {
+		chunk, err := listSessions(sessionService, userID, opts.types, r[0], r[1])
+		if err != nil {
+			return nil, fmt.Errorf("error listing sessions: %v", err)
+		}
+		for _, session := range chunk {
+			// a session crossing a chunk boundary is listed by both chunks
+			if session == nil || (session.Id != "" && seen[session.Id]) {
+				continue
+			}
+			seen[session.Id] = true
+			sessions = append(sessions, session)
+		}
+	}
+	slog.Info("listed sessions", "sessions", len(sessions))
+	if len(sessions) < opts.minSessions {
+		return nil, fmt.Errorf("%w: %d sessions (need %d) from %s to %s with types %v; "+
+			"check the year in -start/-end or widen -types and the filters",
+			errNoData, len(sessions), opts.minSessions,
+			opts.start.Format("2006-01-02"), opts.end.Format("2006-01-02"), opts.types)
+	}
+
+	var aggregates []*fitness.AggregateBy
+	aggregates = append(aggregates, &fitness.AggregateBy{
+		DataTypeName: "com.google.activity.segment",
+	})
+	aggregates = append(aggregates, &fitness.AggregateBy{
+		DataTypeName: "com.google.distance.delta",
+	})
+	all := fetchAllMetrics(opts)
+	if all || usesMetric(opts, "calories") {
+		aggregates = append(aggregates, &fitness.AggregateBy{
+			DataTypeName: "com.google.calories.expended",
+		})
+	}
+	if all || usesMetric(opts, "steps") {
+		aggregates = append(aggregates, &fitness.AggregateBy{
+			DataTypeName: "com.google.step_count.delta",
+		})
+	}
+	if all || usesMetric(opts, "active-minutes") {
+		aggregates = append(aggregates, &fitness.AggregateBy{
+			DataTypeName: "com.google.active_minutes",
+		})
+	}
+	if all || usesMetric(opts, "heart-rate") {
+		aggregates = append(aggregates, &fitness.AggregateBy{
+			DataTypeName: "com.google.heart_rate.bpm",
+		})
+	}
+
+	var activities Activities
+
+	limiter := newLimiter(opts)
+
+	for _, session := range sessions {
+		if err := limiter.Wait(context.TODO()); err != nil {
+			return nil, err
+		}
+		var c = datasetService.Aggregate(userID, &fitness.AggregateRequest{
+			AggregateBy: aggregates,
+			BucketBySession: &fitness.BucketBySession{
+				MinDurationMillis: 100,
+			},
+			EndTimeMillis:   session.EndTimeMillis,
+			StartTimeMillis: session.StartTimeMillis,
+		})
+		r, err := c.Do()
+		if err != nil {
+			return nil, fmt.Errorf("error getting dataset: %v", err)
+		}
+
+		if len(r.Bucket) == 0 {
+			slog.Info("no aggregate data for session", "session", session.Name)
+		}
+		for _, bucket := range r.Bucket {
+			if bucket == nil {
+				continue
+			}
+			if problem := implausibleTimes(bucket, opts.start, opts.end); problem != "" {
+				if opts.badTimestamps == "error" {
+					return nil, fmt.Errorf("session %q: %s", session.Name, problem)
+				}
+				infof("warning: skipping session %q: %s\n", session.Name, problem)
+				continue
+			}
+			activity, ok := bucketActivity(opts, session, bucket)
+			if !ok {
+				slog.Info("skipping bucket outside the requested types",
+					"session", session.Name, "type", activityTypeName(activity.ActivityType))
+				continue
+			}
+			if usesMetric(opts, "effort") {
+				if err := limiter.Wait(context.TODO()); err != nil {
+					return nil, err
+				}
+				activity.Elevation, err = fetchElevationGain(dataSourcesDatasetsService, userID, bucket.StartTimeMillis, bucket.EndTimeMillis)
+				if err != nil {
+					return nil, fmt.Errorf("error getting location samples: %v", err)
+				}
+				activity.Has |= hasElevation
+			}
+			activities = append(activities, activity)
+		}
+	}
+	slog.Info("aggregated activities", "activities", len(activities))
+	return activities, nil
+}
+
+// newLimiter paces requests to -rps, one at a time, to stay under the
+// per-minute quota up front rather than waiting for 429s.
+func newLimiter(opts Options) *rate.Limiter {
+	limit := rate.Inf
+	if opts.rps > 0 {
+		limit = rate.Limit(opts.rps)
+	}
+	return rate.NewLimiter(limit, 1)
+}
+
+// fetchAllMetrics reports whether to aggregate every value Fit has for a
+// session rather than only those the chart needs. The CSV and JSON exports have
+// a column for each, and -serve and -watch can then switch -metric without
+// fetching again; the extra data types ride along in the same request. The
+// elevation for effort is still only fetched when used, as it costs a request
+// per session.
+func fetchAllMetrics(opts Options) bool {
+	return opts.csv != "" || opts.json != "" || opts.serve != "" || opts.watch > 0
+}
+
+// chunkRanges splits start to end into ranges of months months each, or
+// returns it whole when months is 0.
+func chunkRanges(start, end time.Time, months int) [][2]time.Time {
+	if months <= 0 {
+		return [][2]time.Time{{start, end}}
+	}
+	var ranges [][2]time.Time
+	for from := start; from.Before(end); from = from.AddDate(0, months, 0) {
+		to := from.AddDate(0, months, 0).Add(-time.Millisecond)
+		if to.After(end) {
+			to = end
+		}
+		ranges = append(ranges, [2]time.Time{from, to})
+	}
+	return ranges
+}
+
+// listSessions lists every session of the given types from start to end,
+// following the pages of the response.
+func listSessions(service *fitness.UsersSessionsService, userID string, types []int64, start, end time.Time) ([]*fitness.Session, error) {
+	var sessions []*fitness.Session
+	call := service.List(userID)
+	call.StartTime(start.Format(time.RFC3339Nano))
+	call.EndTime(end.Format(time.RFC3339Nano))
+	call.ActivityType(types...)
+	err := call.Pages(context.TODO(), func(resp *fitness.ListSessionsResponse) error {
+		sessions = append(sessions, resp.Session...)
+		return nil
+	})
+	return sessions, err
+}
+
+// implausibleTimes describes what is wrong with a bucket's times, or returns
+// "" when they are usable. Missing times would otherwise plot in 1970 and
+// squash the whole chart against the left edge.
+func implausibleTimes(bucket *fitness.AggregateBucket, start, end time.Time) string {
+	switch {
+	case bucket.StartTimeMillis <= 0 || bucket.EndTimeMillis <= 0:
+		return "missing start or end time"
+	case bucket.EndTimeMillis < bucket.StartTimeMillis:
+		return "ends before it starts"
+	}
+	t := time.UnixMilli(bucket.StartTimeMillis)
+	if t.Before(start) || t.After(end) {
+		return fmt.Sprintf("starts at %s, outside the query range", t.In(start.Location()).Format(time.RFC3339))
+	}
+	return ""
+}
+
+// bucketActivity builds an Activity from one session bucket. The segment
+// summary confirms the activity type, which is checked against -types, and
+// the distance deltas are summed for the distance. It reports false when the
+// bucket turns out to be a type that wasn't asked for.
+func bucketActivity(opts Options, session *fitness.Session, bucket *fitness.AggregateBucket) (Activity, bool) {
+	activity := Activity{
+		Name:         session.Name,
+		Duration:     (bucket.EndTimeMillis - bucket.StartTimeMillis) / 1000 / 60,
+		Description:  session.Description,
+		Date:         time.Unix(bucket.StartTimeMillis/1000, 0).In(opts.location),
+		ActivityType: session.ActivityType,
+	}
+	if bucket.Session != nil {
+		activity.Description = bucket.Session.Description
+	}
+	// the API sometimes leaves datasets, points or values out entirely
+	for _, dataset := range bucket.Dataset {
+		if dataset == nil {
+			continue
+		}
+		switch dataSourceType(dataset.DataSourceId) {
+		case "com.google.activity.summary":
+			if t, ok := segmentActivityType(dataset.Point); ok {
+				activity.ActivityType = t
+			}
+		case "com.google.distance.delta":
+			for _, point := range dataset.Point {
+				if point == nil {
+					continue
+				}
+				for _, v := range point.Value {
+					if v != nil {
+						// rounding is left to display
+						activity.Distance += metersTo("mi", v.FpVal)
+					}
+				}
+			}
+		case "com.google.active_minutes":
+			for _, point := range dataset.Point {
+				if point != nil && len(point.Value) > 0 && point.Value[0] != nil {
+					activity.ActiveMinutes += point.Value[0].IntVal
+					activity.Has |= hasActiveMinutes
+				}
+			}
+		case "com.google.calories.expended":
+			for _, point := range dataset.Point {
+				if point != nil && len(point.Value) > 0 && point.Value[0] != nil {
+					activity.Calories += point.Value[0].FpVal
+					activity.Has |= hasCalories
+				}
+			}
+		case "com.google.step_count.delta":
+			for _, point := range dataset.Point {
+				if point != nil && len(point.Value) > 0 && point.Value[0] != nil {
+					activity.Steps += point.Value[0].IntVal
+					activity.Has |= hasSteps
+				}
+			}
+		case "com.google.heart_rate.summary":
+			for _, point := range dataset.Point {
+				// summary values are average, max, min
+				if point != nil && len(point.Value) > 0 && point.Value[0] != nil {
+					activity.HeartRate = point.Value[0].FpVal
+					activity.Has |= hasHeartRate
+				}
+			}
+		}
+	}
+	if len(opts.types) == 0 {
+		return activity, true
+	}
+	for _, t := range opts.types {
+		if t == activity.ActivityType {
+			return activity, true
+		}
+	}
+	return activity, false
+}
+
+// dataSourceType returns the data type of a data source ID such as
+// "derived:com.google.distance.delta:com.google.android.gms:aggregated", so
+// datasets match whichever app or stream produced them.
+func dataSourceType(id string) string {
+	parts := strings.Split(id, ":")
+	if len(parts) < 2 {
+		return id
+	}
+	return parts[1]
+}
+
+// segmentActivityType returns the activity type that took up the most time in
+// an activity summary, which is more specific than a session labelled as a
+// generic workout. It reports false when there is no usable segment data.
+func segmentActivityType(points []*fitness.DataPoint) (int64, bool) {
+	var best, longest int64
+	for _, point := range points {
+		// summary values are activity type, duration in ms and segment count
+		if point == nil || len(point.Value) < 2 || point.Value[0] == nil || point.Value[1] == nil {
+			continue
+		}
+		// 4 is "unknown", which is never better than the session's own type
+		if t := point.Value[0].IntVal; t != 4 && point.Value[1].IntVal > longest {
+			best, longest = t, point.Value[1].IntVal
+		}
+	}
+	return best, longest > 0
+}
+
+// mergedDistance is Fit's merged stream of every distance change, whether or
+// not it was recorded as part of a session.
+const mergedDistance = "derived:com.google.distance.delta:com.google.android.gms:merge_distance_delta"
+
+// fetchDailyDistance is fetchActivities for -no-sessions. It reads the raw
+// distance data source over the range and sums it into one Activity per day
+// in opts.location, so distance logged by apps that never create sessions is
+// still charted. There is no activity type to go on, so every day is Unknown
+// and -types is ignored.
+func fetchDailyDistance(opts Options, service *fitness.UsersDataSourcesDatasetsService, userID string) (Activities, error) {
+	limiter := newLimiter(opts)
+
+	var activities Activities
+	for _, r := range chunkRanges(opts.start, opts.end, opts.chunk) {
+		datasetID := fmt.Sprintf("%d-%d", r[0].UnixNano(), r[1].UnixNano())
+		call := service.Get(userID, mergedDistance, datasetID)
+		for {
+			if err := limiter.Wait(context.TODO()); err != nil {
+				return nil, err
+			}
+			dataset, err := call.Do()
+			if err != nil {
+				return nil, fmt.Errorf("error getting distance data: %v", err)
+			}
+			for _, point := range dataset.Point {
+				if point == nil || len(point.Value) == 0 || point.Value[0] == nil || point.StartTimeNanos <= 0 {
+					continue
+				}
+				start := time.Unix(0, point.StartTimeNanos).In(opts.location)
+				minutes := (point.EndTimeNanos - point.StartTimeNanos) / int64(time.Minute)
+				last := len(activities) - 1
+				if last >= 0 && sameDay(activities[last].Date, start, opts.location) {
+					activities[last].Distance += metersTo("mi", point.Value[0].FpVal)
+					activities[last].Duration += minutes
+					continue
+				}
+				activities = append(activities, Activity{
+					Name:         "Daily distance",
+					Date:         start,
+					ActivityType: 4,
+					Distance:     metersTo("mi", point.Value[0].FpVal),
+					Duration:     minutes,
+				})
+			}
+			if dataset.NextPageToken == "" {
+				break
+			}
+			call.PageToken(dataset.NextPageToken)
+		}
+	}
+	slog.Info("summed daily distance", "days", len(activities))
+	if len(activities) < opts.minSessions {
+		return nil, fmt.Errorf("%w: distance on %d days (need %d) from %s to %s",
+			errNoData, len(activities), opts.minSessions,
+			opts.start.Format("2006-01-02"), opts.end.Format("2006-01-02"))
+	}
+	return activities, nil
+}
+
+// fetchElevationGain sums every climb between consecutive location samples in
+// the time range. It returns the gain in feet.
+func fetchElevationGain(service *fitness.UsersDataSourcesDatasetsService, userID string, startMillis, endMillis int64) (float64, error) {
+	datasetID := fmt.Sprintf("%d-%d", startMillis*1000000, endMillis*1000000)
+	dataset, err := service.Get(userID, "derived:com.google.location.sample:com.google.android.gms:merge_high_fidelity", datasetID).Do()
+	if err != nil {
+		return 0, err
+	}
+
+	gain := 0.0
+	last := math.NaN()
+	for _, point := range dataset.Point {
+		// location samples are latitude, longitude, accuracy and an optional altitude
+		if point == nil || len(point.Value) < 4 || point.Value[3] == nil {
+			continue
+		}
+		altitude := point.Value[3].FpVal
+		if altitude > last {
+			gain += altitude - last
+		}
+		last = altitude
+	}
+	return metersTo("ft", gain), nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/api/fitness/v1"
+)
+
+const distanceSource = "derived:com.google.distance.delta:com.google.android.gms:aggregated"
+
+// fakeFit answers the sessions list and aggregate calls with canned JSON.
+type fakeFit struct {
+	sessions, aggregate string
+}
+
+func (f fakeFit) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := f.sessions
+	if strings.HasSuffix(req.URL.Path, "dataset:aggregate") {
+		body = f.aggregate
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestBucketActivityNilParts(t *testing.T) {
+	start := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	opts := Options{location: time.UTC}
+	session := &fitness.Session{Name: "Run", ActivityType: 8}
+	tests := []struct {
+		name    string
+		dataset []*fitness.Dataset
+	}{
+		{"nil dataset", []*fitness.Dataset{nil}},
+		{"nil point", []*fitness.Dataset{{DataSourceId: distanceSource, Point: []*fitness.DataPoint{nil}}}},
+		{"nil value", []*fitness.Dataset{{DataSourceId: distanceSource, Point: []*fitness.DataPoint{{Value: []*fitness.Value{nil}}}}}},
+		{"empty value", []*fitness.Dataset{
+			{DataSourceId: distanceSource, Point: []*fitness.DataPoint{{}}},
+			{DataSourceId: "derived:com.google.activity.summary:com.google.android.gms:aggregated", Point: []*fitness.DataPoint{{}}},
+			{DataSourceId: "derived:com.google.calories.expended:com.google.android.gms:aggregated", Point: []*fitness.DataPoint{{}}},
+			{DataSourceId: "derived:com.google.step_count.delta:com.google.android.gms:aggregated", Point: []*fitness.DataPoint{{}}},
+			{DataSourceId: "derived:com.google.active_minutes:com.google.android.gms:aggregated", Point: []*fitness.DataPoint{{}}},
+			{DataSourceId: "derived:com.google.heart_rate.summary:com.google.android.gms:aggregated", Point: []*fitness.DataPoint{{}}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := &fitness.AggregateBucket{
+				StartTimeMillis: start.UnixMilli(),
+				EndTimeMillis:   start.Add(30 * time.Minute).UnixMilli(),
+				Dataset:         tt.dataset,
+			}
+			a, ok := bucketActivity(opts, session, bucket)
+			if !ok {
+				t.Fatal("bucket was rejected")
+			}
+			if a.ActivityType != 8 || a.Duration != 30 || a.Distance != 0 || a.Has != 0 {
+				t.Errorf("got %+v, want a 30 minute run with no data", a)
+			}
+		})
+	}
+}
+
+func TestFetchActivitiesSkipsNilBuckets(t *testing.T) {
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	at := start.Add(8 * time.Hour).UnixMilli()
+	fake := fakeFit{
+		sessions: `{"session": [null, {"id": "a", "name": "Run", "activityType": 8,
+			"startTimeMillis": "` + strconv.FormatInt(at, 10) + `", "endTimeMillis": "` + strconv.FormatInt(at+1800000, 10) + `"}]}`,
+		aggregate: `{"bucket": [null, {"startTimeMillis": "` + strconv.FormatInt(at, 10) + `", "endTimeMillis": "` + strconv.FormatInt(at+1800000, 10) + `",
+			"dataset": [null, {"dataSourceId": "` + distanceSource + `", "point": [null, {"value": []}, {"value": [{"fpVal": 1609.344}]}]}]}]}`,
+	}
+	opts := Options{
+		location: time.UTC,
+		start:    start,
+		end:      start.AddDate(0, 0, 1).Add(-time.Millisecond),
+		metric:   "distance",
+	}
+	activities, err := fetchActivities(opts, &http.Client{Transport: fake}, "me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(activities) != 1 {
+		t.Fatalf("got %d activities, want 1", len(activities))
+	}
+	if d := activities[0].Distance; d < 0.999 || d > 1.001 {
+		t.Errorf("got distance %v, want 1 mile", d)
+	}
+}
+
+func TestBucketActivity(t *testing.T) {
+	summarySource := "derived:com.google.activity.summary:com.google.android.gms:aggregated"
+	start := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	segment := func(activityType, millis int64) *fitness.DataPoint {
+		return &fitness.DataPoint{Value: []*fitness.Value{{IntVal: activityType}, {IntVal: millis}, {IntVal: 1}}}
+	}
+	tests := []struct {
+		name         string
+		types        []int64
+		summary      []*fitness.DataPoint
+		meters       []float64
+		wantType     int64
+		wantDistance float64
+		wantOK       bool
+	}{
+		{
+			name:         "longest segment sets the type",
+			summary:      []*fitness.DataPoint{segment(8, 600000), segment(1, 1800000)},
+			meters:       []float64{1609.344, 3218.688},
+			wantType:     1,
+			wantDistance: 3,
+			wantOK:       true,
+		},
+		{
+			name:         "unknown segment keeps the session type",
+			summary:      []*fitness.DataPoint{segment(4, 3600000)},
+			meters:       []float64{1609.344},
+			wantType:     108,
+			wantDistance: 1,
+			wantOK:       true,
+		},
+		{
+			name:     "segment type outside -types",
+			types:    []int64{8},
+			summary:  []*fitness.DataPoint{segment(1, 3600000)},
+			wantType: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var points []*fitness.DataPoint
+			for _, m := range tt.meters {
+				points = append(points, &fitness.DataPoint{Value: []*fitness.Value{{FpVal: m}}})
+			}
+			bucket := &fitness.AggregateBucket{
+				StartTimeMillis: start.UnixMilli(),
+				EndTimeMillis:   start.Add(45 * time.Minute).UnixMilli(),
+				Dataset: []*fitness.Dataset{
+					{DataSourceId: summarySource, Point: tt.summary},
+					{DataSourceId: distanceSource, Point: points},
+				},
+			}
+			opts := Options{location: time.UTC, types: tt.types}
+			a, ok := bucketActivity(opts, &fitness.Session{Name: "Workout", ActivityType: 108}, bucket)
+			if ok != tt.wantOK {
+				t.Fatalf("got ok %v, want %v", ok, tt.wantOK)
+			}
+			if a.ActivityType != tt.wantType {
+				t.Errorf("got type %d, want %d", a.ActivityType, tt.wantType)
+			}
+			if a.Duration != 45 {
+				t.Errorf("got duration %d, want 45", a.Duration)
+			}
+			if math.Abs(a.Distance-tt.wantDistance) > 1e-9 {
+				t.Errorf("got distance %v, want %v", a.Distance, tt.wantDistance)
+			}
+			if !a.Date.Equal(start) {
+				t.Errorf("got date %s, want %s", a.Date, start)
+			}
+		})
+	}
+}
+
+func TestNewLimiter(t *testing.T) {
+	for rps, want := range map[float64]rate.Limit{0: rate.Inf, 5: 5, 0.5: 0.5} {
+		l := newLimiter(Options{rps: rps})
+		if l.Limit() != want || l.Burst() != 1 {
+			t.Errorf("-rps %v: got limit %v burst %d, want %v burst 1", rps, l.Limit(), l.Burst(), want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// filterActivities returns the activities for which keep returns true.
+func filterActivities(activities Activities, keep func(Activity) bool) Activities {
+	var kept Activities
+	for _, activity := range activities {
+		if keep(activity) {
+			kept = append(kept, activity)
+		}
+	}
+	return kept
+}
+
+// filterByName keeps activities whose name contains substr, ignoring case.
+func filterByName(activities Activities, substr string) Activities {
+	substr = strings.ToLower(substr)
+	return filterActivities(activities, func(a Activity) bool {
+		return strings.Contains(strings.ToLower(a.Name), substr)
+	})
+}
+
+// filterByNameRegex keeps activities whose name matches re.
+func filterByNameRegex(activities Activities, re *regexp.Regexp) Activities {
+	return filterActivities(activities, func(a Activity) bool {
+		return re.MatchString(a.Name)
+	})
+}
+
+// filterByHour keeps activities starting at or after the after hour and before
+// the before hour in loc. Either bound may be -1 to leave it open, and a window
+// with after > before wraps past midnight.
+func filterByHour(activities Activities, after, before int, loc *time.Location) Activities {
+	return filterActivities(activities, func(a Activity) bool {
+		hour := a.Date.In(loc).Hour()
+		switch {
+		case after >= 0 && before >= 0 && after > before:
+			return hour >= after || hour < before
+		case after >= 0 && hour < after:
+			return false
+		case before >= 0 && hour >= before:
+			return false
+		}
+		return true
+	})
+}
+
+// filterByMinSpeed keeps activities averaging at least min in -unit per hour,
+// such as tempo rides rather than recovery spins.
+func filterByMinSpeed(activities Activities, min float64, opts Options) Activities {
+	speed := metrics["speed"].value
+	return filterActivities(activities, func(a Activity) bool {
+		return speed(a, opts) >= min
+	})
+}
+
+// filterByWeekday keeps activities starting on one of days in loc.
+func filterByWeekday(activities Activities, days map[time.Weekday]bool, loc *time.Location) Activities {
+	return filterActivities(activities, func(a Activity) bool {
+		return days[a.Date.In(loc).Weekday()]
+	})
+}
+
+// filterByDate drops activities starting on any of the YYYY-MM-DD days in
+// exclude, in loc, logging each so the right ones can be checked.
+func filterByDate(activities Activities, exclude map[string]bool, loc *time.Location) Activities {
+	return filterActivities(activities, func(a Activity) bool {
+		if !exclude[a.Date.In(loc).Format("2006-01-02")] {
+			return true
+		}
+		infof("excluding %s %q at %s\n", activityTypeName(a.ActivityType), a.Name, a.Date.In(loc).Format("2006-01-02 15:04"))
+		return false
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import "time"
+
+// ghostRange returns the period of the same length just before the range for
+// -ghost previous, and how far forward its activities move to overlay it.
+func ghostRange(opts Options) (start, end time.Time, shift time.Duration) {
+	// ends are inclusive, so the range is a millisecond longer than end-start
+	shift = opts.end.Add(time.Millisecond).Sub(opts.start)
+	return opts.start.Add(-shift), opts.start.Add(-time.Millisecond), shift
+}
+
+// loadGhost loads src's activities from the previous period, moved onto the
+// current one so the ghost line starts where the current one does.
+func loadGhost(opts Options, src source) (series, error) {
+	prev := opts
+	var shift time.Duration
+	prev.start, prev.end, shift = ghostRange(opts)
+	// an empty previous period just draws no ghost
+	prev.minSessions = 0
+	activities, err := loadActivities(prev, src.client, "me")
+	if err != nil {
+		return series{}, err
+	}
+	return shiftedGhost(src.name, activities, shift), nil
+}
+
+// shiftedGhost moves the previous period's activities forward by shift and
+// labels them for the legend.
+func shiftedGhost(name string, activities Activities, shift time.Duration) series {
+	for i := range activities {
+		activities[i].Date = activities[i].Date.Add(shift)
+	}
+	label := "Previous period"
+	if name != "" {
+		label = name + " previous period"
+	}
+	return series{name: label, activities: activities, ghost: true}
+}
+
+// withGhosts puts each source's ghost just before its series, so buildChart
+// gives the two the same color and draws the ghost behind. An unnamed series
+// is named so the legend tells it from its ghost.
+func withGhosts(ghosts, groups []series) []series {
+	if len(ghosts) == 0 {
+		return groups
+	}
+	var merged []series
+	for i, g := range groups {
+		if g.name == "" {
+			g.name = "This period"
+		}
+		merged = append(merged, ghosts[i], g)
+	}
+	return merged
+}
+
+// withoutGhosts drops the ghost series, for everything but their own lines.
+func withoutGhosts(groups []series) []series {
+	var current []series
+	for _, g := range groups {
+		if !g.ghost {
+			current = append(current, g)
+		}
+	}
+	return current
+}
@@ -6,6 +6,7 @@ import (
 	"google.golang.org/api/fitness/v1"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -17,66 +18,165 @@ import (
 	"golang.org/x/oauth2/google"
 )
 
-func getFullClient(secret string) *http.Client {
-	ctx := context.Background()
+// scopes are the read-only Fit scopes requested by both the OAuth and
+// service-account clients.
+var scopes = []string{
+	fitness.FitnessActivityReadScope,
+	fitness.FitnessLocationReadScope,
+	fitness.FitnessBodyReadScope,
+}
+
+// authOptions control how getTokenFromWeb gets the user's consent.
+type authOptions struct {
+	// port is the local port for the OAuth redirect, 0 for any free one
+	port int
+	// manual has the user paste the code back instead of catching the redirect
+	manual bool
+}
 
+// getFullClient returns an OAuth Client for the user. Any *http.Client stored
+// in ctx under oauth2.HTTPClient is used for the underlying transport.
+func getFullClient(ctx context.Context, secret, tokenDir string, auth authOptions) *http.Client {
 	b, err := ioutil.ReadFile(secret)
 	if err != nil {
 		log.Fatalf("Unable to read client secret file: %v", err)
 	}
+	return getFullClientFromJSON(ctx, b, tokenDir, auth)
+}
 
-	//@TODO scope should be separated out
-	config, err := google.ConfigFromJSON(b, fitness.FitnessActivityReadScope, fitness.FitnessLocationReadScope)
+// getFullClientFromJSON is getFullClient for client secret JSON that is
+// already in memory, such as when it is piped in on stdin.
+func getFullClientFromJSON(ctx context.Context, b []byte, tokenDir string, auth authOptions) *http.Client {
+	config, err := google.ConfigFromJSON(b, scopes...)
 	if err != nil {
 		log.Fatalf("Unable to parse client secret file to config: %v", err)
 	}
-	return getClient(ctx, config)
+	return getClient(ctx, config, tokenDir, auth)
+}
+
+// getServiceAccountClient returns a Client that impersonates subject using a
+// service account key with domain-wide delegation. The key's client ID must be
+// granted the Fit scopes in the Workspace admin console.
+func getServiceAccountClient(ctx context.Context, keyFile, subject string) *http.Client {
+	b, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		log.Fatalf("Unable to read service account key file: %v", err)
+	}
+
+	config, err := google.JWTConfigFromJSON(b, scopes...)
+	if err != nil {
+		log.Fatalf("Unable to parse service account key file: %v", err)
+	}
+	config.Subject = subject
+	return config.Client(ctx)
 }
 
 // getClient uses a Context and Config to retrieve a Token
 // then generate a Client. It returns the generated Client.
-func getClient(ctx context.Context, config *oauth2.Config) *http.Client {
-	cacheFile, err := tokenCacheFile()
+func getClient(ctx context.Context, config *oauth2.Config, tokenDir string, auth authOptions) *http.Client {
+	cacheFile, err := tokenCacheFile(tokenDir)
 	if err != nil {
 		log.Fatalf("Unable to get path to cached credential file. %v", err)
 	}
 	tok, err := tokenFromFile(cacheFile)
 	if err != nil {
-		tok = getTokenFromWeb(config)
+		if auth.manual {
+			tok = getTokenManually(ctx, config, auth.port)
+		} else {
+			tok = getTokenFromWeb(ctx, config, auth.port)
+		}
 		saveToken(cacheFile, tok)
 	}
 	return config.Client(ctx, tok)
 }
 
-// getTokenFromWeb uses Config to request a Token.
+// getTokenFromWeb uses Config to request a Token, catching the redirect on a
+// local listener at 127.0.0.1:oauthPort (0 picks a free port). Desktop OAuth
+// clients accept any loopback port; web clients need the exact redirect URI
+// registered in the Cloud console, so pin the port for those.
 // It returns the retrieved Token.
-func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
+func getTokenFromWeb(ctx context.Context, config *oauth2.Config, oauthPort int) *oauth2.Token {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", oauthPort))
+	if err != nil {
+		log.Fatalf("Unable to listen for the OAuth redirect: %v", err)
+	}
+	defer listener.Close()
+	config.RedirectURL = fmt.Sprintf("http://%s/", listener.Addr())
+
+	codes := make(chan string, 1)
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("state") != "state-token" {
+			http.Error(w, "unexpected state", http.StatusBadRequest)
+			return
+		}
+		code := r.URL.Query().Get("code")
+		if code == "" {
+			http.Error(w, "authorization failed: "+r.URL.Query().Get("error"), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "Authorized, you can close this window.")
+		select {
+		case codes <- code:
+		default:
+		}
+	}))
+
 	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Printf("Go to the following link in your browser then type the "+
-		"authorization code: \n%v\n", authURL)
+	// stderr, so the link never ends up in a chart piped from stdout
+	fmt.Fprintf(os.Stderr, "Go to the following link in your browser to authorize: \n%v\n", authURL)
+	code := <-codes
+
+	tok, err := config.Exchange(ctx, code)
+	if err != nil {
+		log.Fatalf("Unable to retrieve token from web %v", err)
+	}
+	return tok
+}
+
+// getTokenManually prints the consent URL and reads the authorization code
+// pasted back by the user, for machines with no local browser. Google no
+// longer supports the out-of-band redirect, so the redirect goes to a
+// localhost page that fails to load on the other device, with the code still
+// in its address bar.
+func getTokenManually(ctx context.Context, config *oauth2.Config, oauthPort int) *oauth2.Token {
+	config.RedirectURL = "http://localhost"
+	if oauthPort != 0 {
+		config.RedirectURL = fmt.Sprintf("http://localhost:%d", oauthPort)
+	}
+	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+	fmt.Fprintf(os.Stderr, "Go to the following link in a browser on any device: \n%v\n"+
+		"After approving, the page will fail to load. Copy the code= value "+
+		"from its address bar and paste it here: ", authURL)
 
 	var code string
 	if _, err := fmt.Scan(&code); err != nil {
 		log.Fatalf("Unable to read authorization code %v", err)
 	}
+	// accept the whole pasted URL too
+	if u, err := url.Parse(code); err == nil && u.Query().Get("code") != "" {
+		code = u.Query().Get("code")
+	}
 
-	tok, err := config.Exchange(oauth2.NoContext, code)
+	tok, err := config.Exchange(ctx, code)
 	if err != nil {
 		log.Fatalf("Unable to retrieve token from web %v", err)
 	}
 	return tok
 }
 
-// tokenCacheFile generates credential file path/filename.
+// tokenCacheFile generates credential file path/filename inside tokenCacheDir,
+// defaulting to ~/.credentials when it is empty.
 // It returns the generated credential path/filename.
-func tokenCacheFile() (string, error) {
-	usr, err := user.Current()
-	if err != nil {
-		return "", err
+func tokenCacheFile(tokenCacheDir string) (string, error) {
+	if tokenCacheDir == "" {
+		usr, err := user.Current()
+		if err != nil {
+			return "", err
+		}
+		tokenCacheDir = filepath.Join(usr.HomeDir, ".credentials")
 	}
-	tokenCacheDir := filepath.Join(usr.HomeDir, ".credentials")
 	// tokenCacheDir := filepath.Join("/tmp/" "credentials")
-	os.MkdirAll(tokenCacheDir, 0700)
+	err := os.MkdirAll(tokenCacheDir, 0700)
 	return filepath.Join(tokenCacheDir, url.QueryEscape("google-auth.json")), err
 }
 
@@ -96,7 +196,7 @@ func tokenFromFile(file string) (*oauth2.Token, error) {
 // saveToken uses a file path to create a file and store the
 // token in it.
 func saveToken(file string, token *oauth2.Token) {
-	fmt.Printf("Saving credential file to: %s\n", file)
+	infof("Saving credential file to: %s\n", file)
 	f, err := os.Create(file)
 	if err != nil {
 		log.Fatalf("Unable to cache oauth token: %v", err)
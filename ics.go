@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// icsTime is the UTC date-time form iCalendar uses.
+const icsTime = "20060102T150405Z"
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// writeICS writes the activities as an iCalendar file for -ics, one event per
+// activity running from its start for its duration.
+func writeICS(w io.Writer, activities Activities, opts Options, now time.Time) error {
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(foldICS(s))
+		b.WriteString("\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//go-fit-graph//" + version + "//EN")
+	for _, a := range activities {
+		start := a.Date.UTC()
+		end := start.Add(time.Duration(a.Duration) * time.Minute)
+		description := fmt.Sprintf("%s %s in %d minutes", formatDistance(convertDistance(a.Distance, opts.unit), opts), opts.unit, a.Duration)
+		if a.Name != "" {
+			description = a.Name + "\n" + description
+		}
+		if a.Description != "" {
+			description += "\n" + a.Description
+		}
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:%d-%d@go-fit-graph", start.Unix(), a.ActivityType))
+		line("DTSTAMP:" + now.UTC().Format(icsTime))
+		line("DTSTART:" + start.Format(icsTime))
+		line("DTEND:" + end.Format(icsTime))
+		line("SUMMARY:" + icsEscaper.Replace(activityTypeName(a.ActivityType)))
+		line("DESCRIPTION:" + icsEscaper.Replace(description))
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// foldICS splits a content line longer than 75 octets onto continuation lines
+// starting with a space, without breaking up a UTF-8 sequence.
+func foldICS(s string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	return b.String()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// jsonActivity is an Activity as written by -json and read back by
+// -from-json. Fields are only ever added, and unknown ones are ignored on
+// read, so files from older and newer versions still load.
+type jsonActivity struct {
+	Name        string    `json:"name,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Date        time.Time `json:"date"`
+	Type        int64     `json:"type"`
+	Duration    int64     `json:"duration_minutes"`
+	Distance    float64   `json:"distance_miles"`
+	// optional values are left out when Fit had no data for them
+	HeartRate     *float64 `json:"heart_rate,omitempty"`
+	Elevation     *float64 `json:"elevation_feet,omitempty"`
+	ActiveMinutes *int64   `json:"active_minutes,omitempty"`
+	Calories      *float64 `json:"calories,omitempty"`
+	Steps         *int64   `json:"steps,omitempty"`
+}
+
+// writeJSON writes the charted activities as a JSON array for -from-json to
+// re-render later.
+func writeJSON(w io.Writer, activities Activities) error {
+	out := make([]jsonActivity, 0, len(activities))
+	for _, a := range activities {
+		a := a // the optional fields point into it
+		j := jsonActivity{
+			Name:        a.Name,
+			Description: a.Description,
+			Date:        a.Date,
+			Type:        a.ActivityType,
+			Duration:    a.Duration,
+			Distance:    a.Distance,
+		}
+		if a.Has&hasHeartRate != 0 {
+			j.HeartRate = &a.HeartRate
+		}
+		if a.Has&hasElevation != 0 {
+			j.Elevation = &a.Elevation
+		}
+		if a.Has&hasActiveMinutes != 0 {
+			j.ActiveMinutes = &a.ActiveMinutes
+		}
+		if a.Has&hasCalories != 0 {
+			j.Calories = &a.Calories
+		}
+		if a.Has&hasSteps != 0 {
+			j.Steps = &a.Steps
+		}
+		out = append(out, j)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// readJSON loads the activities in a -json file that fall within the query
+// range and types, standing in for fetchActivities.
+func readJSON(path string, opts Options) (Activities, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var in []jsonActivity
+	if err := json.NewDecoder(f).Decode(&in); err != nil {
+		return nil, err
+	}
+	wanted := map[int64]bool{}
+	for _, t := range opts.types {
+		wanted[t] = true
+	}
+
+	var activities Activities
+	for _, a := range in {
+		if a.Date.Before(opts.start) || a.Date.After(opts.end) {
+			continue
+		}
+		if len(wanted) > 0 && !wanted[a.Type] {
+			continue
+		}
+		activity := Activity{
+			Name:         a.Name,
+			Description:  a.Description,
+			Date:         a.Date.In(opts.location),
+			ActivityType: a.Type,
+			Duration:     a.Duration,
+			Distance:     a.Distance,
+		}
+		if a.HeartRate != nil {
+			activity.HeartRate, activity.Has = *a.HeartRate, activity.Has|hasHeartRate
+		}
+		if a.Elevation != nil {
+			activity.Elevation, activity.Has = *a.Elevation, activity.Has|hasElevation
+		}
+		if a.ActiveMinutes != nil {
+			activity.ActiveMinutes, activity.Has = *a.ActiveMinutes, activity.Has|hasActiveMinutes
+		}
+		if a.Calories != nil {
+			activity.Calories, activity.Has = *a.Calories, activity.Has|hasCalories
+		}
+		if a.Steps != nil {
+			activity.Steps, activity.Has = *a.Steps, activity.Has|hasSteps
+		}
+		activities = append(activities, activity)
+	}
+	return activities, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/wcharczuk/go-chart"
+)
+
+// applyLayout sets the canvas size, padding and font sizes from the options.
+// Zero or negative values leave go-chart's defaults alone.
+func applyLayout(opts Options, graph *chart.Chart) {
+	graph.Width = opts.width
+	graph.Height = opts.height
+	if opts.padding >= 0 {
+		graph.Background.Padding = chart.NewBox(opts.padding, opts.padding, opts.padding, opts.padding)
+	}
+	if opts.fontSize > 0 {
+		graph.TitleStyle.FontSize = opts.fontSize
+		for _, axis := range []*chart.YAxis{&graph.YAxis, &graph.YAxisSecondary} {
+			axis.NameStyle.FontSize = opts.fontSize
+			axis.Style.FontSize = opts.fontSize
+		}
+		graph.XAxis.NameStyle.FontSize = opts.fontSize
+		graph.XAxis.Style.FontSize = opts.fontSize
+	}
+}
+
+// applyBarLayout is applyLayout for bar charts.
+func applyBarLayout(opts Options, graph *chart.BarChart) {
+	graph.Width = opts.width
+	graph.Height = opts.height
+	if opts.padding >= 0 {
+		graph.Background.Padding = chart.NewBox(opts.padding, opts.padding, opts.padding, opts.padding)
+	}
+	if opts.fontSize > 0 {
+		graph.TitleStyle.FontSize = opts.fontSize
+		graph.XAxis.FontSize = opts.fontSize
+		graph.YAxis.Style.FontSize = opts.fontSize
+		graph.YAxis.NameStyle.FontSize = opts.fontSize
+	}
+}
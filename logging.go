@@ -0,0 +1,37 @@
+package main
+
+import (
+	"log"
+	"log/slog"
+	"os"
+)
+
+// quiet is set by -quiet to drop everything but errors from stderr.
+var quiet bool
+
+// setupLogging switches the standard logger to JSON lines when format is
+// "json". Routing through slog.SetDefault means existing log.Printf calls are
+// emitted as JSON too, so only the calls that add fields need to use slog.
+//
+// With -quiet the slog records below error are dropped instead, and the
+// standard logger goes straight to stderr so fatal errors still show.
+func setupLogging(format string, silent bool) {
+	quiet = silent
+	if quiet {
+		level := &slog.HandlerOptions{Level: slog.LevelError}
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, level)))
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+		return
+	}
+	if format == "json" {
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+	}
+}
+
+// infof logs progress and warnings, which -quiet suppresses.
+func infof(format string, args ...interface{}) {
+	if !quiet {
+		log.Printf(format, args...)
+	}
+}
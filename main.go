@@ -1,206 +1,273 @@
 package main
 
 import (
-	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
-	"math"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
 	"time"
-
-	"github.com/wcharczuk/go-chart"
-	"google.golang.org/api/fitness/v1"
-	"google.golang.org/api/option"
 )
 
-type Activity struct {
-	Name         string
-	Duration     int64
-	Distance     float64
-	Description  string
-	Date         time.Time
-	ActivityType int64
+// exitNoData is the exit status when the query matched too few sessions to
+// chart, so scripts can tell an empty range apart from a failure.
+const exitNoData = 3
+
+// errNoData is wrapped by errors for queries that matched too few sessions.
+var errNoData = errors.New("no data")
+
+// configPaths returns the client secret path and token cache directory. An
+// empty token directory means the historical ~/.credentials location.
+func configPaths(opts Options) (secret, tokenDir string) {
+	if opts.configDir != "" {
+		return filepath.Join(opts.configDir, "client_secret.json"), opts.configDir
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		log.Fatalf("unable to find config dir: %v\n", err)
+	}
+	return filepath.Join(configDir, "gem/fitness/client_secret.json"), ""
 }
 
-type Activities []Activity
+// loadActivities fetches userID's activities then filters, dedupes and sorts
+// them ready for charting.
+func loadActivities(opts Options, client *http.Client, userID string) (Activities, error) {
+	activities, err := sourceActivities(opts, client, userID)
+	if err != nil {
+		return nil, err
+	}
+	activities = prepareActivities(opts, activities)
+	warnIfNoMetricData(opts, activities)
+	return activities, nil
+}
 
-func (e Activities) Len() int {
-	return len(e)
+// sourceActivities gets userID's activities as they come: made up for -demo,
+// sliced up for -session, read from -from-json or fetched from the API, plus
+// any from -import.
+func sourceActivities(opts Options, client *http.Client, userID string) (Activities, error) {
+	var activities Activities
+	var err error
+	switch {
+	case opts.demo:
+		activities = demoActivities(opts)
+	case opts.session != "":
+		if activities, err = fetchSession(opts, client, userID); err != nil {
+			return nil, err
+		}
+	case opts.fromJSON != "":
+		if activities, err = readJSON(opts.fromJSON, opts); err != nil {
+			return nil, fmt.Errorf("error reading -from-json: %v", err)
+		}
+	default:
+		if activities, err = fetchActivities(opts, client, userID); err != nil {
+			return nil, err
+		}
+	}
+	if opts.importDir != "" {
+		imported, err := readImportDir(opts.importDir, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error importing: %v", err)
+		}
+		activities = append(activities, imported...)
+	}
+	return activities, nil
 }
 
-func (e Activities) Less(i, j int) bool {
-	return e[i].Date.Before(e[j].Date)
+// prepareActivities filters, dedupes and sorts activities for charting. It
+// may sort activities in place.
+func prepareActivities(opts Options, activities Activities) Activities {
+	if opts.nameContains != "" {
+		activities = filterByName(activities, opts.nameContains)
+	}
+	if opts.nameRegex != nil {
+		activities = filterByNameRegex(activities, opts.nameRegex)
+	}
+	if opts.afterHour >= 0 || opts.beforeHour >= 0 {
+		activities = filterByHour(activities, opts.afterHour, opts.beforeHour, opts.location)
+	}
+	if len(opts.weekdays) > 0 {
+		activities = filterByWeekday(activities, opts.weekdays, opts.location)
+	}
+	if opts.minSpeed > 0 {
+		activities = filterByMinSpeed(activities, opts.minSpeed, opts)
+	}
+	if len(opts.excludeDates) > 0 {
+		activities = filterByDate(activities, opts.excludeDates, opts.location)
+	}
+
+	switch {
+	case opts.noDedupe:
+		infof("warning: -no-dedupe set, duplicate activities will not be removed\n")
+	case opts.dedupeWindow > 0:
+		// removeDuplicatesWithin compares neighbours, so sort first
+		sort.Sort(activities)
+		activities = removeDuplicatesWithin(activities, opts.dedupeWindow)
+	default:
+		activities = removeDuplicates(activities)
+	}
+	sort.Sort(activities)
+	if opts.maxDistance > 0 {
+		activities = dropOutliers(activities, opts.maxDistance, opts.unit, opts.precision)
+	}
+	if opts.mergeDaily {
+		activities = mergeDaily(dailyActivities(opts, activities), opts.location)
+	}
+	return activities
 }
 
-func (e Activities) Swap(i, j int) {
-	e[i], e[j] = e[j], e[i]
+// source is one account to fetch, labelled with name when charting several.
+type source struct {
+	name   string
+	client *http.Client
 }
 
-func removeDuplicates(activities Activities) Activities {
-	var dedupe Activities
-	seen := map[string]bool{}
-	for _, activity := range activities {
-		if seen[activity.Date.String()] == false {
-			dedupe = append(dedupe, activity)
-			seen[activity.Date.String()] = true
+// sources builds the HTTP clients once so repeated runs reuse them.
+func sources(opts Options) []source {
+	ctx := httpContext(opts)
+	if len(opts.users) == 0 {
+		secret, tokenDir := configPaths(opts)
+		auth := authOptions{port: opts.oauthPort, manual: opts.manualAuth}
+		if opts.clientSecretStdin {
+			b, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				log.Fatalf("unable to read client secret from stdin: %v\n", err)
+			}
+			return []source{{client: getFullClientFromJSON(ctx, b, tokenDir, auth)}}
 		}
+		return []source{{client: getFullClient(ctx, secret, tokenDir, auth)}}
 	}
-	return dedupe
+	var srcs []source
+	for _, user := range opts.users {
+		srcs = append(srcs, source{name: user, client: getServiceAccountClient(ctx, opts.serviceAccount, user)})
+	}
+	return srcs
 }
 
-func main() {
-	configDir, err := os.UserConfigDir()
-	if err != nil {
-		log.Fatalf("unable to find config dir: %v\n", err)
+// loadSource loads the series to chart for one source: its activities, or
+// this year against last for -ytd-compare.
+func loadSource(opts Options, src source) ([]series, error) {
+	if opts.ytdCompare {
+		return ytdCompare(opts, src, time.Now())
 	}
-	path := filepath.Join(configDir, "gem/fitness/client_secret.json")
-	client := getFullClient(path)
-	fitnessService, err := fitness.NewService(context.TODO(), option.WithHTTPClient(client))
-	if err != nil {
-		log.Fatalf("%v\n", err.Error())
-	}
-
-	datasetService := fitness.NewUsersDatasetService(fitnessService)
-	sessionService := fitness.NewUsersSessionsService(fitnessService)
-
-	call := sessionService.List("me")
-	call.StartTime("2020-01-01T00:00:00.000Z")
-	call.EndTime("2020-12-31T23:59:59.000Z")
-	// https://developers.google.com/fit/rest/v1/reference/activity-types
-	//  1 = Biking
-	// 15 = Mountain Biking
-	// 16 = Road Biking
-	// 17 = Spinning
-	// 18 = Stationary Biking
-	// 19 = Utility Biking even though I don't think I've ever done this
-	//  8 = Running
-	call.ActivityType(1, 15, 16, 17, 18, 19, 8)
-	resp, err := call.Do()
+	// the Fit API only accepts "me", which resolves to the impersonated user
+	activities, err := loadActivities(opts, src.client, "me")
 	if err != nil {
-		log.Fatalf("%v", err.Error())
+		return nil, err
 	}
+	return []series{{name: src.name, activities: activities}}, nil
+}
 
-	var aggregates []*fitness.AggregateBy
-	aggregates = append(aggregates, &fitness.AggregateBy{
-		DataTypeName: "com.google.activity.segment",
-	})
-	aggregates = append(aggregates, &fitness.AggregateBy{
-		DataTypeName: "com.google.distance.delta",
-	})
+// run fetches every source and writes the chart in each requested format.
+func run(opts Options, srcs []source) error {
+	warnInsecure(opts)
+	if opts.ytdCompare {
+		opts.start, opts.end = ytdRange(time.Now(), opts.location)
+		opts.cumulative = true
+	}
+	var groups, ghosts []series
+	for _, src := range srcs {
+		loaded, err := loadSource(opts, src)
+		if err == nil && opts.ghost != "" {
+			var ghost series
+			ghost, err = loadGhost(opts, src)
+			ghosts = append(ghosts, ghost)
+		}
+		if err != nil {
+			if src.name != "" {
+				return fmt.Errorf("%s: %w", src.name, err)
+			}
+			return err
+		}
+		groups = append(groups, loaded...)
+	}
 
-	var activities Activities
+	toStdout, err := writeExports(opts, groups)
+	if err != nil {
+		return err
+	}
+	// an export already went to stdout, so the chart can only go to -out
+	skipChart := toStdout && opts.out == ""
+	if opts.perMonth {
+		return writePerMonth(opts, groups)
+	}
+	if skipChart && opts.report == "" {
+		return nil
+	}
+
+	graph, err := buildGraph(opts, withGhosts(ghosts, groups))
+	if err != nil {
+		return err
+	}
 
-	for _, session := range resp.Session {
-		var c = datasetService.Aggregate("me", &fitness.AggregateRequest{
-			AggregateBy: aggregates,
-			BucketBySession: &fitness.BucketBySession{
-				MinDurationMillis: 100,
-			},
-			EndTimeMillis:   session.EndTimeMillis,
-			StartTimeMillis: session.StartTimeMillis,
+	if opts.report != "" {
+		err := writeFileAtomic(opts.report, func(w io.Writer) error {
+			return writeReport(w, graph, allActivities(groups), opts)
 		})
-		r, err := c.Do()
 		if err != nil {
-			log.Fatalf("error getting dataset: %v\n", err)
+			return fmt.Errorf("error writing report: %v", err)
 		}
+	}
+	if skipChart {
+		return nil
+	}
 
-		for _, bucket := range r.Bucket {
-			timestamp := time.Unix(bucket.StartTimeMillis/1000, 0)
-
-			activity := Activity{
-				Name:         session.Name,
-				Duration:     (bucket.EndTimeMillis - bucket.StartTimeMillis) / 1000 / 60,
-				Distance:     0,
-				Description:  bucket.Session.Description,
-				Date:         timestamp,
-				ActivityType: session.ActivityType,
-			}
-			for _, dataset := range bucket.Dataset {
-				if dataset.DataSourceId == "derived:com.google.distance.delta:com.google.android.gms:aggregated" {
-					for _, points := range dataset.Point {
-						for _, v := range points.Value {
-							// convert meters to miles and round
-							var round float64
-							dist := v.FpVal / 1609.344
-							pow := math.Pow(10, 2.0)
-							digit := pow * dist
-							_, div := math.Modf(digit)
-							if div >= 0.5 {
-								round = math.Ceil(digit)
-							} else {
-								round = math.Floor(digit)
-							}
-							activity.Distance = round / pow
-						}
-					}
-				}
-			}
-			activities = append(activities, activity)
+	for _, format := range opts.formats {
+		if err := writeOutput(graph, opts, format); err != nil {
+			return fmt.Errorf("error rending graph: %v", err)
 		}
 	}
+	return nil
+}
 
-	activities = removeDuplicates(activities)
-	sort.Sort(activities)
+func main() {
+	opts, err := parseOptions(os.Args[1:], os.Stderr)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	if opts.version {
+		fmt.Println(versionString())
+		os.Exit(0)
+	}
+	setupLogging(opts.logFormat, opts.quiet)
+
+	// -demo makes up its data and -from-json has it already, so there are no
+	// credentials to load
+	srcs := []source{{}}
+	if !opts.demo && opts.fromJSON == "" {
+		srcs = sources(opts)
+	}
 
-	var ys []float64
-	var xs []float64
-	totalDist := 0.0
+	if opts.serve != "" {
+		warnInsecure(opts)
+		log.Fatal(serve(opts, srcs[0].client))
+	}
 
-	for _, activity := range activities {
-		if activity.Distance != 0 {
-			totalDist = totalDist + activity.Distance
-			ys = append(ys, totalDist)
-			xs = append(xs, float64(activity.Date.Unix()))
+	if opts.watch > 0 {
+		for {
+			// a failed run leaves the previous output in place
+			if err := run(opts, srcs); err != nil {
+				log.Printf("%v; keeping previous output\n", err)
+			}
+			time.Sleep(opts.watch)
 		}
 	}
 
-	jan := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
-	graph := chart.Chart{
-		YAxis: chart.YAxis{
-			Name: "Miles",
-			Ticks: []chart.Tick{
-				{Value: 0, Label: "0"},
-				{Value: 100, Label: "100"},
-				{Value: 200, Label: "200"},
-				{Value: 300, Label: "300"},
-				{Value: 400, Label: "400"},
-				{Value: 500, Label: "500"},
-				{Value: 600, Label: "600"},
-				{Value: 700, Label: "700"},
-				{Value: 800, Label: "800"},
-				{Value: 900, Label: "900"},
-				{Value: 1000, Label: "1000"},
-			},
-		},
-		XAxis: chart.XAxis{
-			Name: "Date",
-			Ticks: []chart.Tick{
-				{Value: float64(jan.Unix()), Label: "2020-01"},
-				{Value: float64(jan.AddDate(0, 1, 0).Unix()), Label: "2020-02"},
-				{Value: float64(jan.AddDate(0, 2, 0).Unix()), Label: "2020-03"},
-				{Value: float64(jan.AddDate(0, 3, 0).Unix()), Label: "2020-04"},
-				{Value: float64(jan.AddDate(0, 4, 0).Unix()), Label: "2020-05"},
-				{Value: float64(jan.AddDate(0, 5, 0).Unix()), Label: "2020-06"},
-				{Value: float64(jan.AddDate(0, 6, 0).Unix()), Label: "2020-07"},
-				{Value: float64(jan.AddDate(0, 7, 0).Unix()), Label: "2020-08"},
-				{Value: float64(jan.AddDate(0, 8, 0).Unix()), Label: "2020-09"},
-				{Value: float64(jan.AddDate(0, 9, 0).Unix()), Label: "2020-10"},
-				{Value: float64(jan.AddDate(0, 10, 0).Unix()), Label: "2020-11"},
-				{Value: float64(jan.AddDate(0, 11, 0).Unix()), Label: "2020-12"},
-				{Value: float64(jan.AddDate(0, 12, 0).Unix()), Label: "2021-01"},
-			},
-		},
-		Series: []chart.Series{
-			chart.ContinuousSeries{
-				XValues: xs,
-				YValues: ys,
-			},
-		},
-	}
-
-	err = graph.Render(chart.SVG, os.Stdout)
+	stopProfiles := startProfiles(opts)
+	err = run(opts, srcs)
+	stopProfiles()
 	if err != nil {
-		log.Fatalf("error rending graph: %v", err.Error())
+		if errors.Is(err, errNoData) {
+			log.Printf("%v\n", err)
+			os.Exit(exitNoData)
+		}
+		log.Fatalf("%v\n", err)
 	}
 }
@@ -0,0 +1,97 @@
+package main
+
+// metric is a per-activity value that can be charted.
+type metric struct {
+	label func(opts Options) string
+	value func(activity Activity, opts Options) float64
+}
+
+func staticLabel(label string) func(Options) string {
+	return func(Options) string { return label }
+}
+
+// metrics maps the -metric and -secondary-metric values to how they are read
+// off an Activity.
+var metrics = map[string]metric{
+	"distance": {func(opts Options) string {
+		return units[opts.unit].distance
+	}, func(a Activity, opts Options) float64 {
+		return convertDistance(a.Distance, opts.unit)
+	}},
+	"effort": {staticLabel("Effort"), func(a Activity, opts Options) float64 {
+		return convertDistance(a.Distance, opts.unit) + a.Elevation*opts.effortFactor
+	}},
+	"heart-rate": {staticLabel("Avg heart rate (bpm)"), func(a Activity, _ Options) float64 {
+		return a.HeartRate
+	}},
+	"duration": {staticLabel("Duration (min)"), func(a Activity, _ Options) float64 {
+		return float64(a.Duration)
+	}},
+	"active-minutes": {staticLabel("Active minutes"), func(a Activity, _ Options) float64 {
+		return float64(a.ActiveMinutes)
+	}},
+	"calories": {staticLabel("Calories (kcal)"), func(a Activity, _ Options) float64 {
+		return a.Calories
+	}},
+	"steps": {staticLabel("Steps"), func(a Activity, _ Options) float64 {
+		return float64(a.Steps)
+	}},
+	// count sums to the number of activities, per bucket or cumulatively
+	"count": {staticLabel("Activities"), func(Activity, Options) float64 {
+		return 1
+	}},
+	"speed": {func(opts Options) string {
+		return "Avg speed (" + units[opts.unit].speed + ")"
+	}, func(a Activity, opts Options) float64 {
+		// zero-duration activities have no meaningful speed
+		if a.Duration <= 0 {
+			return 0
+		}
+		return convertDistance(a.Distance, opts.unit) / (float64(a.Duration) / 60)
+	}},
+}
+
+// metricUnit is the unit a metric's values are in, for labelling a single
+// value: the -unit for distance, or the metric's label otherwise.
+func metricUnit(opts Options) string {
+	if opts.metric == "distance" {
+		return opts.unit
+	}
+	return metrics[opts.metric].label(opts)
+}
+
+// metricSources are the Fit data types each metric is read from, for
+// warnings when one came back empty.
+var metricSources = map[string]string{
+	"distance":       "com.google.distance.delta",
+	"effort":         "com.google.distance.delta and com.google.location.sample",
+	"heart-rate":     "com.google.heart_rate.bpm",
+	"duration":       "com.google.activity.segment",
+	"active-minutes": "com.google.active_minutes",
+	"calories":       "com.google.calories.expended",
+	"steps":          "com.google.step_count.delta",
+	"speed":          "com.google.distance.delta",
+}
+
+// warnIfNoMetricData warns when none of activities has a value for the
+// charted metric, which otherwise just draws a flat line at zero. Usually the
+// account has never recorded that kind of data.
+func warnIfNoMetricData(opts Options, activities Activities) {
+	source, ok := metricSources[opts.metric]
+	if !ok || len(activities) == 0 {
+		return
+	}
+	m := metrics[opts.metric]
+	for _, a := range activities {
+		if m.value(a, opts) != 0 {
+			return
+		}
+	}
+	infof("warning: none of the %d activities has any %s data (from %s); "+
+		"this account may never have recorded it\n", len(activities), opts.metric, source)
+}
+
+// usesMetric reports whether name is charted on either axis.
+func usesMetric(opts Options, name string) bool {
+	return opts.metric == name || opts.secondaryMetric == name
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// note is an event such as "new bike" marked on the timeline.
+type note struct {
+	date  time.Time
+	label string
+}
+
+// readNotesFile parses "YYYY-MM-DD,label" lines in loc. Blank lines and lines
+// starting with # are skipped.
+func readNotesFile(path string, loc *time.Location) ([]note, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var notes []note
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		date, label, ok := strings.Cut(text, ",")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected date,label", path, line)
+		}
+		t, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(date), loc)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		notes = append(notes, note{date: t, label: strings.TrimSpace(label)})
+	}
+	return notes, scanner.Err()
+}
+
+var noteStyle = chart.Style{
+	StrokeColor:     drawing.ColorFromHex("888888"),
+	StrokeDashArray: []float64{2, 2},
+}
+
+// addNotes draws a labelled vertical line from minY to maxY for each note
+// between start and end, warning about any that fall outside.
+func addNotes(graph *chart.Chart, notes []note, start, end time.Time, minY, maxY float64) {
+	var annotations []chart.Value2
+	for _, n := range notes {
+		if n.date.Before(start) || n.date.After(end) {
+			infof("warning: note %q on %s is outside the chart's dates, skipping\n", n.label, n.date.Format("2006-01-02"))
+			continue
+		}
+		x := float64(n.date.Unix())
+		graph.Series = append(graph.Series, chart.ContinuousSeries{
+			Style:   noteStyle,
+			XValues: []float64{x, x},
+			YValues: []float64{minY, maxY},
+		})
+		annotations = append(annotations, chart.Value2{XValue: x, YValue: maxY, Label: n.label})
+	}
+	if len(annotations) > 0 {
+		graph.Series = append(graph.Series, chart.AnnotationSeries{Annotations: annotations})
+	}
+}
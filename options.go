@@ -0,0 +1,646 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// importDedupeWindow is -dedupe-window's default with -import.
+const importDedupeWindow = 2 * time.Minute
+
+// Options holds everything that decides what is fetched and drawn. The CLI
+// fills it from flags with parseOptions. Code building one directly can leave
+// fields at their zero value and call Validate to get the flag defaults.
+type Options struct {
+	noDedupe             bool
+	secondaryMetric      string
+	maxDistance          float64
+	formats              []string
+	dataURIFormat        string
+	rps                  float64
+	out                  string
+	configDir            string
+	metric               string
+	effortFactor         float64
+	cumulative           bool
+	nameContains         string
+	nameRegex            *regexp.Regexp
+	location             *time.Location
+	afterHour            int
+	beforeHour           int
+	goals                []float64
+	asPercent            bool
+	logFormat            string
+	serve                string
+	cacheSize            int
+	cacheTTL             time.Duration
+	bucketsFile          string
+	precision            int
+	serviceAccount       string
+	users                []string
+	watch                time.Duration
+	width                int
+	height               int
+	padding              int
+	fontSize             float64
+	types                []int64
+	strict               bool
+	unit                 string
+	start                time.Time
+	end                  time.Time
+	influx               string
+	prom                 string
+	clientSecretStdin    bool
+	insecure             bool
+	caBundle             string
+	notes                []note
+	style                string
+	minSessions          int
+	table                bool
+	ytdCompare           bool
+	report               string
+	sortOrder            string
+	splitByType          bool
+	colors               map[int64]drawing.Color
+	bucket               string
+	yLabel               string
+	xLabel               string
+	step                 bool
+	cpuProfile           string
+	memProfile           string
+	query                string
+	demo                 bool
+	oauthPort            int
+	chunk                int
+	quiet                bool
+	chartType            string
+	shadeWeekends        bool
+	stats                bool
+	highlightStreak      bool
+	mergeDaily           bool
+	logY                 bool
+	version              bool
+	typeUnits            map[int64]string
+	tsv                  string
+	annotateDescriptions bool
+	badTimestamps        string
+	calendar             int
+	minSpeed             float64
+	manualAuth           bool
+	annotateTotal        bool
+	json                 string
+	fromJSON             string
+	markers              bool
+	project              bool
+	userAgent            string
+	csv                  string
+	smooth               bool
+	noSessions           bool
+	weekStart            time.Weekday
+	animate              bool
+	topN                 int
+	compact              bool
+	importDir            string
+	dedupeWindow         time.Duration
+	dumpResponses        string
+	xAnchor              string
+	ics                  string
+	trend                bool
+	perMonth             bool
+	roundMode            string
+	ghost                string
+	annotateActiveDays   bool
+	session              string
+	sessionBucket        time.Duration
+	splitMidnight        bool
+	// yTickCount is roughly how many ticks the Y axis gets
+	yTickCount int
+	// monthStep is how many months apart X axis ticks are
+	monthStep int
+	// planStart is day 1 of -plan-start's plan, zero when not set
+	planStart time.Time
+	// excludeDates are YYYY-MM-DD days in location whose activities are dropped
+	excludeDates map[string]bool
+	// weekdays are the days of the week, in location, whose activities are kept
+	weekdays map[time.Weekday]bool
+}
+
+// parseOptions parses and validates command line style args, writing usage to
+// output on a bad flag. The server reuses it for each request so query
+// parameters get exactly the same checks.
+func parseOptions(args []string, output io.Writer) (Options, error) {
+	if name, ok := argValue(args, "query"); ok {
+		configDir, ok := argValue(args, "config-dir")
+		if !ok {
+			configDir = os.Getenv("GOFITGRAPH_CONFIG_DIR")
+		}
+		preset, err := queryArgs(name, configDir, time.Now())
+		if err != nil {
+			return Options{}, err
+		}
+		args = append(preset, args...)
+	}
+
+	var opts Options
+	var formats, nameRegex, tz, goals, users, types, start, end, notesFile, colorsFile, excludeDates, weekStart, planStart, weekdays string
+	var utc bool
+	fs := flag.NewFlagSet("fitness", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.BoolVar(&opts.noDedupe, "no-dedupe", false, "skip removing duplicate activities (debugging only)")
+	fs.StringVar(&opts.metric, "metric", "distance", "metric to chart (distance, effort, heart-rate, duration, active-minutes, calories, steps, speed, count)")
+	fs.Float64Var(&opts.effortFactor, "effort-factor", 0.01, "effort added per foot of elevation gain by -metric effort")
+	fs.BoolVar(&opts.cumulative, "cumulative", true, "plot the running total rather than one point per activity")
+	fs.StringVar(&opts.secondaryMetric, "secondary-metric", "", "plot a second metric on the right Y axis (distance, effort, heart-rate, duration, active-minutes, calories, steps, speed, count)")
+	fs.Float64Var(&opts.maxDistance, "max-activity-distance", 0, "drop activities longer than this distance in -unit (0 disables)")
+	fs.StringVar(&formats, "format", "svg", "comma-separated output formats (svg, png, jpeg, webp, datauri)")
+	fs.StringVar(&opts.dataURIFormat, "datauri-format", "svg", "image format embedded by -format datauri (svg, png, jpeg, webp)")
+	fs.Float64Var(&opts.rps, "rps", 5, "maximum aggregate requests per second sent to the Fit API (0 for no limit)")
+	fs.StringVar(&opts.out, "out", "", "output path; the extension is replaced per format (default stdout)")
+	fs.StringVar(&opts.configDir, "config-dir", os.Getenv("GOFITGRAPH_CONFIG_DIR"), "directory holding the client secret, token and caches (env GOFITGRAPH_CONFIG_DIR)")
+	fs.StringVar(&opts.nameContains, "name-contains", "", "only chart activities whose name contains this text (case-insensitive)")
+	fs.StringVar(&nameRegex, "name-regex", "", "only chart activities whose name matches this regular expression")
+	fs.StringVar(&tz, "tz", "Local", "IANA time zone used for dates and hours, e.g. America/Chicago")
+	fs.BoolVar(&utc, "utc", false, "use UTC for dates, hours and X axis labels (same as -tz UTC)")
+	fs.IntVar(&opts.afterHour, "after-hour", -1, "only chart activities starting at or after this hour (0-23)")
+	fs.IntVar(&opts.beforeHour, "before-hour", -1, "only chart activities starting before this hour (0-23)")
+	fs.StringVar(&goals, "goal", "", "comma-separated goal values, each drawn as a line (e.g. 1000,1500)")
+	fs.BoolVar(&opts.asPercent, "as-percent", false, "plot the metric as a percentage of the first -goal")
+	fs.StringVar(&opts.logFormat, "log-format", "text", "log output format (text, json)")
+	fs.StringVar(&opts.serve, "serve", "", "serve charts over HTTP on this address (e.g. :8080) instead of rendering once")
+	fs.IntVar(&opts.cacheSize, "cache-size", 32, "number of rendered charts kept in memory by -serve")
+	fs.DurationVar(&opts.cacheTTL, "cache-ttl", 10*time.Minute, "how long -serve reuses a rendered chart")
+	fs.StringVar(&opts.bucketsFile, "buckets-file", "", "file of YYYY-MM-DD [label] boundaries; draws a bar per period")
+	fs.IntVar(&opts.precision, "precision", 2, "decimal places shown for distances and axis labels")
+	fs.StringVar(&opts.serviceAccount, "service-account", "", "service account key file with domain-wide delegation, used with -users")
+	fs.StringVar(&users, "users", "", "comma-separated user emails to impersonate, one line each (requires -service-account)")
+	fs.DurationVar(&opts.watch, "watch", 0, "re-render every interval (e.g. 15m), rewriting -out each time")
+	fs.IntVar(&opts.width, "width", 0, "chart width in pixels (0 for the go-chart default)")
+	fs.IntVar(&opts.height, "height", 0, "chart height in pixels (0 for the go-chart default)")
+	fs.IntVar(&opts.padding, "padding", -1, "padding around the chart in pixels (-1 for the go-chart default)")
+	fs.Float64Var(&opts.fontSize, "font-size", 0, "font size for the title and axis labels (0 for the go-chart default)")
+	// Biking (1, 15-19) and Running (8) by default
+	fs.StringVar(&types, "types", "1,15,16,17,18,19,8", "comma-separated Fit activity type IDs to query")
+	fs.BoolVar(&opts.strict, "strict", false, "fail on unknown -types IDs instead of warning")
+	fs.StringVar(&opts.unit, "unit", "mi", "distance unit (mi, km, m); per-type units can be set in units.json for -split-by-type")
+	year := strconv.Itoa(time.Now().Year())
+	fs.StringVar(&start, "start", year+"-01-01", "first day to chart, YYYY-MM-DD")
+	fs.StringVar(&end, "end", year+"-12-31", "last day to chart, YYYY-MM-DD (inclusive)")
+	fs.StringVar(&opts.influx, "influx", "", "also write activities as InfluxDB line protocol to this file (- for stdout)")
+	fs.StringVar(&opts.prom, "prom", "", "also write totals as a Prometheus textfile for node_exporter (- for stdout)")
+	fs.BoolVar(&opts.clientSecretStdin, "client-secret-stdin", false, "read the OAuth client secret JSON from stdin; the token must already be cached")
+	fs.BoolVar(&opts.insecure, "insecure", false, "skip TLS certificate verification, e.g. behind an intercepting proxy (dangerous)")
+	fs.StringVar(&opts.caBundle, "ca-bundle", "", "PEM file of extra CA certificates to trust, e.g. a corporate proxy's")
+	fs.StringVar(&notesFile, "notes-file", "", "file of date,label lines marked as vertical lines on the chart")
+	fs.StringVar(&opts.style, "style", "line", "how to draw the metric (line, scatter)")
+	fs.IntVar(&opts.minSessions, "min-sessions", 1, "fewest sessions to accept before exiting with a no-data diagnostic")
+	fs.BoolVar(&opts.table, "table", false, "print the activities as a text table to stdout")
+	fs.BoolVar(&opts.ytdCompare, "ytd-compare", false, "overlay this year to date on last year, cumulative by day of year (ignores -start/-end)")
+	fs.StringVar(&opts.report, "report", "", "also write an HTML report with the chart, summary stats and activities to this file")
+	fs.StringVar(&opts.sortOrder, "sort", "asc", "order of the -table, -report and -csv activity rows by date (asc, desc)")
+	fs.BoolVar(&opts.splitByType, "split-by-type", false, "draw a separate line for each activity type")
+	fs.StringVar(&colorsFile, "colors-file", "", "file of type,#rrggbb lines coloring each type's line with -split-by-type")
+	fs.StringVar(&opts.bucket, "bucket", "", "draw bars totalling the metric per calendar period (week, month, quarter)")
+	fs.StringVar(&opts.yLabel, "ylabel", "", "override the Y axis label (the title of bar charts)")
+	fs.StringVar(&opts.xLabel, "xlabel", "", "override the X axis label")
+	fs.BoolVar(&opts.step, "step", false, "draw the cumulative line as steps, flat between activities")
+	fs.StringVar(&opts.cpuProfile, "cpuprofile", "", "write a CPU profile of a single run to this file")
+	fs.StringVar(&opts.memProfile, "memprofile", "", "write a heap profile after a single run to this file")
+	fs.StringVar(&opts.query, "query", "", "load the types, range, unit and metric saved under this name in queries.json; other flags still override them")
+	fs.BoolVar(&opts.demo, "demo", false, "chart generated sample data instead of calling the API")
+	fs.IntVar(&opts.oauthPort, "oauth-port", 0, "local port for the OAuth redirect, 0 for any free one; must match the redirect URI registered for web clients")
+	fs.IntVar(&opts.chunk, "chunk", 0, "list sessions this many months at a time, for long ranges (0 for all at once)")
+	fs.BoolVar(&opts.quiet, "quiet", false, "only print errors to stderr")
+	fs.StringVar(&opts.chartType, "chart", "line", "kind of chart (line, stacked-area, quarter-bars, top-n, pie); stacked-area sums each type per -bucket period, top-n bars the -n biggest activities, pie shares the total by type")
+	fs.BoolVar(&opts.shadeWeekends, "shade-weekends", false, "shade each Saturday and Sunday behind the line chart")
+	fs.BoolVar(&opts.stats, "stats", false, "print totals and daily and weekly streaks to stdout")
+	fs.BoolVar(&opts.highlightStreak, "highlight-streak", false, "shade the current daily streak on the line chart")
+	fs.BoolVar(&opts.mergeDaily, "merge-daily", false, "combine each day's activities into one point")
+	fs.BoolVar(&opts.logY, "log-y", false, "use a logarithmic Y axis; values of zero or below are left out")
+	fs.BoolVar(&opts.version, "version", false, "print the version, commit and build date then exit")
+	fs.StringVar(&opts.tsv, "tsv", "", "also write the line chart's plotted points as TSV to this file (- for stdout)")
+	fs.BoolVar(&opts.annotateDescriptions, "annotate-descriptions", false, "label activities that have a description in Fit with it on the line chart")
+	fs.StringVar(&opts.badTimestamps, "bad-timestamps", "skip", "what to do with sessions whose times are missing or outside the range (skip, error)")
+	fs.IntVar(&opts.calendar, "calendar", 0, "print a grid of this many weeks to stdout, each day shaded by the metric")
+	fs.Float64Var(&opts.minSpeed, "min-speed", 0, "drop activities averaging less than this many -unit per hour")
+	fs.BoolVar(&opts.manualAuth, "manual-auth", false, "print the consent URL and read the authorization code from stdin, for headless machines")
+	fs.BoolVar(&opts.annotateTotal, "annotate-total", false, "label the end of each cumulative line with its total")
+	fs.StringVar(&opts.json, "json", "", "also write the activities as JSON to this file (- for stdout), for -from-json")
+	fs.StringVar(&opts.fromJSON, "from-json", "", "chart the activities in a file written by -json instead of calling the API")
+	fs.BoolVar(&opts.markers, "markers", false, "draw a dot at each activity on the line (shrinks with many activities)")
+	fs.StringVar(&excludeDates, "exclude-dates", "", "comma-separated YYYY-MM-DD days whose activities are dropped, e.g. bogus auto-detections")
+	fs.BoolVar(&opts.project, "project", false, "extend each cumulative line to the end of the range at its current pace, dashed")
+	fs.StringVar(&opts.userAgent, "user-agent", "go-fit-graph/"+version, "application name sent to the Fit API in the User-Agent, shown in the Cloud console")
+	fs.StringVar(&opts.csv, "csv", "", "also write every activity with all its values (calories, steps, ...) as CSV to this file (- for stdout)")
+	fs.BoolVar(&opts.smooth, "smooth", false, "draw the line as a smooth curve; cosmetic only, it hides that totals jump at each activity")
+	fs.BoolVar(&opts.noSessions, "no-sessions", false, "sum raw distance data into one entry per day, for data logged without sessions (ignores -types)")
+	fs.StringVar(&weekStart, "week-start", "monday", "first day of the week for weekly buckets, streaks, stats and -calendar (monday, sunday)")
+	fs.BoolVar(&opts.animate, "animate", false, "animate SVG output so each line draws itself from start to finish")
+	fs.IntVar(&opts.topN, "n", 10, "number of activities charted by -chart top-n")
+	fs.BoolVar(&opts.compact, "compact", false, "small fonts, little padding and fewer ticks, for embedding at small sizes; -padding and -font-size still override it")
+	fs.StringVar(&planStart, "plan-start", "", "label the X axis in days of a training plan starting on this YYYY-MM-DD")
+	fs.StringVar(&opts.importDir, "import", "", "directory of .tcx files to chart alongside the fetched activities")
+	fs.DurationVar(&opts.dedupeWindow, "dedupe-window", 0, "treat activities starting within this long of each other with similar distances as duplicates, keeping the most complete (default 2m with -import, otherwise 0 for identical start times only)")
+	fs.StringVar(&opts.dumpResponses, "dump-responses", "", "write each Fit API response body to a numbered JSON file in this directory, for bug reports")
+	fs.StringVar(&opts.xAnchor, "x-anchor", "start", "plot each activity at its start or end time (start, end)")
+	fs.StringVar(&opts.ics, "ics", "", "also write the activities as an iCalendar file to this file (- for stdout), one event each")
+	fs.BoolVar(&opts.trend, "trend", false, "draw a least-squares trend line through each line's activities, dotted, and print its slope with -stats")
+	fs.BoolVar(&opts.perMonth, "per-month", false, "write a separate chart of each month in the range, named after -out and the month (e.g. ride-2021-01.svg)")
+	fs.StringVar(&opts.roundMode, "round-mode", "nearest", "how distances are rounded to -precision for display and export (nearest, floor, ceil)")
+	fs.StringVar(&opts.ghost, "ghost", "", "draw another period faintly behind the line chart, moved onto the range (previous: the same length just before it)")
+	fs.StringVar(&weekdays, "weekdays", "", "only keep activities starting on these days in -tz, e.g. mon,tue,wed or mon-fri; ranges run in -week-start order, wrapping past the end of the week")
+	fs.BoolVar(&opts.annotateActiveDays, "annotate-active-days", false, "show how many days had an activity above the top right of the chart")
+	fs.StringVar(&opts.session, "session", "", "chart the session with this ID on its own, in -session-bucket slices, instead of every activity in the range")
+	fs.DurationVar(&opts.sessionBucket, "session-bucket", time.Minute, "length of each slice of a -session chart, at least 1m")
+	fs.BoolVar(&opts.splitMidnight, "split-midnight", false, "share an activity running past midnight between the days by time, for -merge-daily, -calendar and -annotate-active-days; by default it counts on the day it started")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	for _, format := range strings.Split(formats, ",") {
+		format = strings.TrimSpace(format)
+		if _, ok := extensions[format]; !ok {
+			return opts, fmt.Errorf("unknown format: %q", format)
+		}
+		opts.formats = append(opts.formats, format)
+	}
+	if nameRegex != "" {
+		re, err := regexp.Compile(nameRegex)
+		if err != nil {
+			return opts, fmt.Errorf("invalid -name-regex: %v", err)
+		}
+		opts.nameRegex = re
+	}
+	if utc {
+		tz = "UTC"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return opts, fmt.Errorf("invalid -tz: %v", err)
+	}
+	opts.location = loc
+	if opts.start, opts.end, err = parseDateRange(start, end, loc); err != nil {
+		return opts, err
+	}
+	if notesFile != "" {
+		if opts.notes, err = readNotesFile(notesFile, loc); err != nil {
+			return opts, err
+		}
+	}
+	if opts.splitByType {
+		// lets multi-sport charts measure, say, swimming in meters
+		if opts.typeUnits, err = readTypeUnits(opts.configDir); err != nil {
+			return opts, err
+		}
+	}
+	if colorsFile != "" {
+		if opts.colors, err = readColorsFile(colorsFile); err != nil {
+			return opts, err
+		}
+	}
+	if goals != "" {
+		for _, g := range strings.Split(goals, ",") {
+			goal, err := strconv.ParseFloat(strings.TrimSpace(g), 64)
+			if err != nil || goal <= 0 {
+				return opts, fmt.Errorf("invalid -goal value: %q", g)
+			}
+			opts.goals = append(opts.goals, goal)
+		}
+	}
+	if planStart != "" {
+		if opts.planStart, err = time.ParseInLocation("2006-01-02", planStart, loc); err != nil {
+			return opts, fmt.Errorf("invalid -plan-start: %v", err)
+		}
+	}
+	if excludeDates != "" {
+		opts.excludeDates = map[string]bool{}
+		for _, d := range strings.Split(excludeDates, ",") {
+			d = strings.TrimSpace(d)
+			if _, err := time.Parse("2006-01-02", d); err != nil {
+				return opts, fmt.Errorf("invalid -exclude-dates value: %q", d)
+			}
+			opts.excludeDates[d] = true
+		}
+	}
+	switch weekStart {
+	case "monday":
+		opts.weekStart = time.Monday
+	case "sunday":
+		opts.weekStart = time.Sunday
+	default:
+		return opts, fmt.Errorf("unknown -week-start: %q", weekStart)
+	}
+	if weekdays != "" {
+		if opts.weekdays, err = parseWeekdays(weekdays, opts.weekStart); err != nil {
+			return opts, err
+		}
+	}
+	opts.yTickCount, opts.monthStep = 10, 1
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if opts.importDir != "" && !set["dedupe-window"] {
+		// the same workout from Fit and a .tcx file rarely starts on the
+		// same second
+		opts.dedupeWindow = importDedupeWindow
+	}
+	if opts.compact {
+		if !set["padding"] {
+			opts.padding = 4
+		}
+		if !set["font-size"] {
+			opts.fontSize = 8
+		}
+		opts.yTickCount = 4
+		// about six month labels however long the range
+		opts.monthStep = (monthsBetween(opts.start, opts.end) + 6) / 6
+	}
+	if users != "" {
+		for _, user := range strings.Split(users, ",") {
+			opts.users = append(opts.users, strings.TrimSpace(user))
+		}
+	}
+	for _, t := range strings.Split(types, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64)
+		if err != nil {
+			return opts, fmt.Errorf("invalid -types value: %q", t)
+		}
+		if _, ok := activityTypes[id]; !ok {
+			// an unknown ID silently matches nothing, so call it out
+			if opts.strict {
+				return opts, fmt.Errorf("unknown activity type %d in -types", id)
+			}
+			if !opts.quiet {
+				log.Printf("warning: unknown activity type %d in -types, it will likely match nothing\n", id)
+			}
+		}
+		opts.types = append(opts.types, id)
+	}
+	return opts, opts.validate()
+}
+
+// Validate fills in the flag defaults for fields left at a zero value that
+// means nothing, such as an empty metric or unit, then reports the first
+// option that is out of range or conflicts with another. Zero values that
+// mean something, such as cumulative false or no -rps limit, are kept.
+func (opts *Options) Validate() error {
+	defaults, err := parseOptions(nil, io.Discard)
+	if err != nil {
+		return err
+	}
+	for _, f := range []struct{ v, d *string }{
+		{&opts.metric, &defaults.metric},
+		{&opts.unit, &defaults.unit},
+		{&opts.dataURIFormat, &defaults.dataURIFormat},
+		{&opts.badTimestamps, &defaults.badTimestamps},
+		{&opts.sortOrder, &defaults.sortOrder},
+		{&opts.style, &defaults.style},
+		{&opts.chartType, &defaults.chartType},
+		{&opts.logFormat, &defaults.logFormat},
+		{&opts.xAnchor, &defaults.xAnchor},
+		{&opts.roundMode, &defaults.roundMode},
+		{&opts.userAgent, &defaults.userAgent},
+	} {
+		if *f.v == "" {
+			*f.v = *f.d
+		}
+	}
+	if len(opts.formats) == 0 {
+		opts.formats = defaults.formats
+	}
+	if len(opts.types) == 0 {
+		opts.types = defaults.types
+	}
+	if opts.location == nil {
+		opts.location = defaults.location
+	}
+	if opts.start.IsZero() || opts.end.IsZero() {
+		year := strconv.Itoa(time.Now().In(opts.location).Year())
+		start, end, _ := parseDateRange(year+"-01-01", year+"-12-31", opts.location)
+		if opts.start.IsZero() {
+			opts.start = start
+		}
+		if opts.end.IsZero() {
+			opts.end = end
+		}
+	}
+	// before midnight matches nothing unless the window wraps from -after-hour
+	if opts.beforeHour == 0 && opts.afterHour <= 0 {
+		opts.beforeHour = -1
+	}
+	if opts.importDir != "" && opts.dedupeWindow == 0 {
+		opts.dedupeWindow = importDedupeWindow
+	}
+	if opts.topN == 0 {
+		opts.topN = defaults.topN
+	}
+	if opts.sessionBucket == 0 {
+		opts.sessionBucket = defaults.sessionBucket
+	}
+	if opts.cacheTTL == 0 {
+		opts.cacheTTL = defaults.cacheTTL
+	}
+	if opts.yTickCount == 0 {
+		opts.yTickCount = defaults.yTickCount
+	}
+	if opts.monthStep == 0 {
+		opts.monthStep = defaults.monthStep
+	}
+	return opts.validate()
+}
+
+// validate reports the first option that is out of range or conflicts with
+// another. parseOptions calls it once every flag has been converted, so an
+// explicit zero on the command line is checked rather than defaulted.
+func (opts Options) validate() error {
+	if _, ok := metrics[opts.metric]; !ok {
+		return fmt.Errorf("unknown metric: %q", opts.metric)
+	}
+	if _, ok := metrics[opts.secondaryMetric]; opts.secondaryMetric != "" && !ok {
+		return fmt.Errorf("unknown secondary metric: %q", opts.secondaryMetric)
+	}
+	if opts.afterHour < -1 || opts.afterHour > 23 || opts.beforeHour < -1 || opts.beforeHour > 23 {
+		return errors.New("-after-hour and -before-hour must be between 0 and 23")
+	}
+	if opts.asPercent && len(opts.goals) == 0 {
+		return errors.New("-as-percent requires a -goal")
+	}
+	if opts.asPercent && opts.secondaryMetric != "" {
+		// the goal is in the primary metric, so it says nothing about the other
+		return errors.New("-as-percent can't be combined with -secondary-metric")
+	}
+	if opts.badTimestamps != "skip" && opts.badTimestamps != "error" {
+		return fmt.Errorf("unknown -bad-timestamps: %q", opts.badTimestamps)
+	}
+	if _, ok := mimeTypes[opts.dataURIFormat]; !ok {
+		return fmt.Errorf("unknown -datauri-format: %q", opts.dataURIFormat)
+	}
+	if opts.sortOrder != "asc" && opts.sortOrder != "desc" {
+		return fmt.Errorf("unknown sort order: %q", opts.sortOrder)
+	}
+	if opts.bucket != "" && opts.bucket != "week" && opts.bucket != "month" && opts.bucket != "quarter" {
+		return fmt.Errorf("unknown bucket: %q", opts.bucket)
+	}
+	if opts.dedupeWindow < 0 {
+		return errors.New("-dedupe-window must not be negative")
+	}
+	if opts.topN < 1 {
+		return errors.New("-n must be at least 1")
+	}
+	if opts.bucket != "" && opts.bucketsFile != "" {
+		return errors.New("-bucket and -buckets-file can't be used together")
+	}
+	if opts.chartType != "line" && opts.chartType != "stacked-area" && opts.chartType != "quarter-bars" && opts.chartType != "top-n" && opts.chartType != "pie" {
+		return fmt.Errorf("unknown chart: %q", opts.chartType)
+	}
+	if opts.chartType != "line" && opts.bucketsFile != "" {
+		return fmt.Errorf("-chart %s can't be used with -buckets-file", opts.chartType)
+	}
+	if opts.annotateTotal && !opts.cumulative {
+		return errors.New("-annotate-total requires -cumulative")
+	}
+	if opts.project && !opts.cumulative {
+		return errors.New("-project requires -cumulative")
+	}
+	if opts.xAnchor != "start" && opts.xAnchor != "end" {
+		return fmt.Errorf("unknown -x-anchor: %q", opts.xAnchor)
+	}
+	if opts.trend && opts.cumulative {
+		return errors.New("-trend requires -cumulative=false")
+	}
+	if opts.trend && opts.logY {
+		return errors.New("-trend can't be combined with -log-y")
+	}
+	if opts.smooth && opts.step {
+		return errors.New("-smooth and -step are mutually exclusive")
+	}
+	if opts.step && !opts.cumulative {
+		return errors.New("-step requires -cumulative")
+	}
+	if opts.style != "line" && opts.style != "scatter" {
+		return fmt.Errorf("unknown style: %q", opts.style)
+	}
+	if _, ok := units[opts.unit]; !ok {
+		return fmt.Errorf("unknown unit: %q", opts.unit)
+	}
+	if opts.roundMode != "nearest" && opts.roundMode != "floor" && opts.roundMode != "ceil" {
+		return fmt.Errorf("unknown -round-mode: %q", opts.roundMode)
+	}
+	if opts.precision < 0 {
+		return errors.New("-precision can't be negative")
+	}
+	if len(opts.users) > 0 && opts.serviceAccount == "" {
+		return errors.New("-users requires -service-account")
+	}
+	if opts.logFormat != "text" && opts.logFormat != "json" {
+		return fmt.Errorf("unknown log format: %q", opts.logFormat)
+	}
+	if opts.manualAuth && opts.clientSecretStdin {
+		return errors.New("-manual-auth reads the code from stdin, so it can't be used with -client-secret-stdin")
+	}
+	if opts.ghost != "" && opts.ghost != "previous" {
+		return fmt.Errorf("unknown -ghost: %q", opts.ghost)
+	}
+	if opts.ghost != "" && (opts.chartType != "line" || opts.bucket != "" || opts.bucketsFile != "" || opts.splitByType || opts.ytdCompare || opts.perMonth) {
+		return errors.New("-ghost only works with a single line chart, without -bucket, -buckets-file, -split-by-type, -ytd-compare or -per-month")
+	}
+	if opts.annotateActiveDays && (opts.chartType == "pie" || opts.chartType == "top-n") {
+		return fmt.Errorf("-annotate-active-days can't be used with -chart %s", opts.chartType)
+	}
+	if opts.session != "" && opts.sessionBucket < time.Minute {
+		return errors.New("-session-bucket must be at least 1m")
+	}
+	if opts.session != "" && (opts.demo || opts.fromJSON != "" || opts.noSessions || opts.ytdCompare || opts.ghost != "" || opts.perMonth) {
+		return errors.New("-session can't be combined with -demo, -from-json, -no-sessions, -ytd-compare, -ghost or -per-month")
+	}
+	if opts.session != "" && (opts.chartType != "line" || opts.bucket != "" || opts.bucketsFile != "") {
+		return errors.New("-session only draws a line chart, without -bucket or -buckets-file")
+	}
+	if opts.perMonth && opts.out == "" {
+		return errors.New("-per-month requires -out")
+	}
+	if opts.perMonth && opts.report != "" {
+		return errors.New("-per-month can't be combined with -report; run them separately")
+	}
+	if opts.perMonth && opts.ytdCompare {
+		return errors.New("-per-month can't be combined with -ytd-compare")
+	}
+	if opts.watch > 0 && opts.out == "" {
+		return errors.New("-watch requires -out")
+	}
+	if len(opts.formats) > 1 && opts.out == "" && opts.serve == "" {
+		return errors.New("-out is required when writing more than one format")
+	}
+	return nil
+}
+
+// weekdayNames maps the abbreviations -weekdays accepts to their days.
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseWeekdays parses -weekdays: abbreviations and ranges separated by
+// commas. A range runs forward through the week starting on weekStart and
+// wraps from its last day to its first, so with -week-start sunday sat-mon is
+// the Saturday ending one week and the Sunday and Monday starting the next.
+func parseWeekdays(s string, weekStart time.Weekday) (map[time.Weekday]bool, error) {
+	// the days of the week in -week-start order
+	var week []time.Weekday
+	for i := 0; i < 7; i++ {
+		week = append(week, (weekStart+time.Weekday(i))%7)
+	}
+	index := func(d time.Weekday) int { return int(d-weekStart+7) % 7 }
+	days := map[time.Weekday]bool{}
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(strings.ToLower(strings.TrimSpace(part)), "-")
+		if !isRange {
+			to = from
+		}
+		first, ok := weekdayNames[from]
+		last, ok2 := weekdayNames[to]
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid -weekdays value: %q", part)
+		}
+		i, j := index(first), index(last)
+		if i > j {
+			// past the end of the week and on from its start
+			j += 7
+		}
+		for ; i <= j; i++ {
+			days[week[i%7]] = true
+		}
+	}
+	return days, nil
+}
+
+// parseDateRange parses -start and -end in loc. The end is pushed to the last
+// millisecond of its day so activities late on the final day are included.
+func parseDateRange(start, end string, loc *time.Location) (time.Time, time.Time, error) {
+	s, err := time.ParseInLocation("2006-01-02", start, loc)
+	if err != nil {
+		return s, s, fmt.Errorf("invalid -start: %v", err)
+	}
+	e, err := time.ParseInLocation("2006-01-02", end, loc)
+	if err != nil {
+		return s, e, fmt.Errorf("invalid -end: %v", err)
+	}
+	e = e.AddDate(0, 0, 1).Add(-time.Millisecond)
+	if !e.After(s) {
+		return s, e, errors.New("-end must not be before -start")
+	}
+	return s, e, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestParseDateRangeInZone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end, err := parseDateRange("2021-01-01", "2021-01-31", tokyo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2021, 1, 1, 0, 0, 0, 0, tokyo); !start.Equal(want) {
+		t.Errorf("got start %s, want %s", start, want)
+	}
+	if want := time.Date(2021, 1, 31, 23, 59, 59, int(999*time.Millisecond), tokyo); !end.Equal(want) {
+		t.Errorf("got end %s, want %s", end, want)
+	}
+	if end.Location() != tokyo {
+		t.Errorf("got end in %s, want %s", end.Location(), tokyo)
+	}
+}
+
+func TestParseOptionsDataURIFormat(t *testing.T) {
+	base := []string{"-start=2021-01-01", "-end=2021-12-31", "-tz=UTC"}
+	for _, format := range []string{"svg", "png", "jpeg"} {
+		if _, err := parseOptions(append(base, "-datauri-format="+format), io.Discard); err != nil {
+			t.Errorf("-datauri-format=%s: %v", format, err)
+		}
+	}
+	if _, err := parseOptions(append(base, "-datauri-format=gif"), io.Discard); err == nil {
+		t.Error("-datauri-format=gif was accepted")
+	}
+}
+
+func TestZeroOptionsValidate(t *testing.T) {
+	var opts Options
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("zero Options: %v", err)
+	}
+	if opts.metric != "distance" || opts.unit != "mi" || opts.location == nil || !opts.end.After(opts.start) {
+		t.Errorf("defaults not applied: metric %q, unit %q, range %s to %s", opts.metric, opts.unit, opts.start, opts.end)
+	}
+	activities := Activities{{Date: opts.start.Add(8 * time.Hour), ActivityType: 1, Duration: 30, Distance: 10}}
+	if len(prepareActivities(opts, activities)) != 1 {
+		t.Error("the default options filtered out an activity")
+	}
+	g, err := buildGraph(opts, []series{{activities: activities}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := render(g, "svg", "", io.Discard); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateKeepsSetFields(t *testing.T) {
+	opts := Options{metric: "steps", unit: "km", topN: 3}
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if opts.metric != "steps" || opts.unit != "km" || opts.topN != 3 || opts.cumulative {
+		t.Errorf("Validate changed set fields: %+v", opts)
+	}
+	opts = Options{metric: "bogus"}
+	if err := opts.Validate(); err == nil {
+		t.Error("an unknown metric was accepted")
+	}
+}
+
+func TestParseOptionsWeekdaysFollowWeekStart(t *testing.T) {
+	base := []string{"-start=2021-01-01", "-end=2021-12-31", "-tz=UTC"}
+	tests := []struct {
+		args []string
+		want []time.Weekday
+	}{
+		{[]string{"-week-start=sunday", "-weekdays=sat-mon"}, []time.Weekday{time.Saturday, time.Sunday, time.Monday}},
+		{[]string{"-week-start=sunday", "-weekdays=sun-tue"}, []time.Weekday{time.Sunday, time.Monday, time.Tuesday}},
+		{[]string{"-week-start=monday", "-weekdays=sun-tue"}, []time.Weekday{time.Sunday, time.Monday, time.Tuesday}},
+		{[]string{"-week-start=monday", "-weekdays=mon-fri,sun"}, []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Sunday}},
+	}
+	for _, tt := range tests {
+		opts, err := parseOptions(append(append([]string{}, base...), tt.args...), io.Discard)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if len(opts.weekdays) != len(tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.args, opts.weekdays, tt.want)
+			continue
+		}
+		for _, d := range tt.want {
+			if !opts.weekdays[d] {
+				t.Errorf("%v: got %v, want %v", tt.args, opts.weekdays, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseOptionsImportDedupeWindow(t *testing.T) {
+	base := []string{"-start=2021-01-01", "-end=2021-12-31", "-tz=UTC"}
+	tests := []struct {
+		args []string
+		want time.Duration
+	}{
+		{nil, 0},
+		{[]string{"-import=tcx"}, 2 * time.Minute},
+		{[]string{"-import=tcx", "-dedupe-window=0"}, 0},
+		{[]string{"-import=tcx", "-dedupe-window=5m"}, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		opts, err := parseOptions(append(append([]string{}, base...), tt.args...), io.Discard)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if opts.dedupeWindow != tt.want {
+			t.Errorf("%v: got -dedupe-window %s, want %s", tt.args, opts.dedupeWindow, tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// pieOther is the smallest share of the total, in percent, a type gets its
+// own slice for; smaller ones are lumped into "Other".
+const pieOther = 3.0
+
+// buildPieChart draws one slice per activity type sized by its total of the
+// metric for -chart pie, colored as with -split-by-type.
+func buildPieChart(opts Options, activities Activities) chart.PieChart {
+	m := metrics[opts.metric]
+	totals := map[int64]float64{}
+	total := 0.0
+	for _, a := range activities {
+		v := m.value(a, opts)
+		totals[a.ActivityType] += v
+		total += v
+	}
+	var ids []int64
+	for id := range totals {
+		ids = append(ids, id)
+	}
+	// biggest first, so slices run clockwise from largest to smallest
+	sort.Slice(ids, func(i, j int) bool {
+		if totals[ids[i]] != totals[ids[j]] {
+			return totals[ids[i]] > totals[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	var values []chart.Value
+	other := 0.0
+	for _, id := range ids {
+		if total <= 0 || totals[id] <= 0 {
+			continue
+		}
+		if share := totals[id] / total * 100; share < pieOther {
+			other += totals[id]
+			continue
+		}
+		values = append(values, pieSlice(activityTypeName(id), totals[id], total, typeColor(opts.colors, id)))
+	}
+	if other > 0 {
+		values = append(values, pieSlice("Other", other, total, drawing.ColorFromHex("aaaaaa")))
+	}
+	if len(values) == 0 {
+		// go-chart refuses to draw a pie with nothing in it
+		values = append(values, chart.Value{Value: 1, Label: "No activities", Style: chart.Style{FillColor: drawing.ColorFromHex("dddddd")}})
+	}
+
+	title := m.label(opts) + " by type"
+	if opts.yLabel != "" {
+		title = opts.yLabel
+	}
+	graph := chart.PieChart{
+		Background: chart.Style{
+			Padding: chart.Box{Top: 50, Left: 10, Right: 10, Bottom: 10},
+		},
+		Values: values,
+	}
+	graph.Width, graph.Height = opts.width, opts.height
+	if graph.Width == 0 && graph.Height == 0 {
+		// go-chart's default canvas is wide, which leaves a small pie
+		graph.Width, graph.Height = 512, 512
+	}
+	if opts.padding >= 0 {
+		graph.Background.Padding = chart.NewBox(opts.padding+40, opts.padding, opts.padding, opts.padding)
+	}
+	titleSize := 18.0
+	if opts.fontSize > 0 {
+		titleSize = opts.fontSize
+		graph.SliceStyle.FontSize = opts.fontSize
+	}
+	// go-chart draws the title over the top of the pie, so put it in the
+	// padding above instead
+	graph.Elements = []chart.Renderable{func(r chart.Renderer, _ chart.Box, defaults chart.Style) {
+		box := chart.Box{Top: 10, Right: graph.GetWidth(), Bottom: graph.Background.Padding.Top}
+		chart.Draw.TextWithin(r, title, box, chart.Style{
+			Font:                defaults.Font,
+			FontSize:            titleSize,
+			FontColor:           chart.DefaultTextColor,
+			TextHorizontalAlign: chart.TextHorizontalAlignCenter,
+			TextVerticalAlign:   chart.TextVerticalAlignMiddle,
+		})
+	}}
+	return graph
+}
+
+// pieSlice is one labelled slice of v out of total.
+func pieSlice(name string, v, total float64, color drawing.Color) chart.Value {
+	return chart.Value{
+		Value: v,
+		Label: fmt.Sprintf("%s %.0f%%", name, v/total*100),
+		Style: chart.Style{FillColor: color, StrokeColor: drawing.ColorWhite},
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfiles starts the -cpuprofile profile, if any. The returned func
+// stops it and writes the -memprofile heap profile.
+func startProfiles(opts Options) func() {
+	var cpu *os.File
+	if opts.cpuProfile != "" {
+		var err error
+		if cpu, err = os.Create(opts.cpuProfile); err != nil {
+			log.Fatalf("unable to create CPU profile: %v\n", err)
+		}
+		if err := pprof.StartCPUProfile(cpu); err != nil {
+			log.Fatalf("unable to start CPU profile: %v\n", err)
+		}
+	}
+	return func() {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			cpu.Close()
+		}
+		if opts.memProfile != "" {
+			f, err := os.Create(opts.memProfile)
+			if err != nil {
+				log.Printf("unable to create memory profile: %v\n", err)
+				return
+			}
+			defer f.Close()
+			// get up-to-date statistics
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				log.Printf("unable to write memory profile: %v\n", err)
+			}
+		}
+	}
+}
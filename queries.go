@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// query is a saved preset in queries.json, keyed by the name given to -query.
+// Empty fields leave the flag at its default.
+type query struct {
+	Types  string `json:"types"`
+	Range  string `json:"range"`
+	Unit   string `json:"unit"`
+	Metric string `json:"metric"`
+}
+
+// configFile returns the path of the named file beside the client secret.
+func configFile(configDir, name string) (string, error) {
+	if configDir != "" {
+		return filepath.Join(configDir, name), nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gem/fitness", name), nil
+}
+
+// queryArgs loads the named query from the config dir and returns it as
+// flags, to go ahead of the command line ones so those still override it.
+func queryArgs(name, configDir string, now time.Time) ([]string, error) {
+	path, err := configFile(configDir, "queries.json")
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read saved queries: %v", err)
+	}
+	var queries map[string]query
+	if err := json.Unmarshal(b, &queries); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	q, ok := queries[name]
+	if !ok {
+		return nil, fmt.Errorf("no query %q in %s", name, path)
+	}
+
+	var args []string
+	if q.Types != "" {
+		args = append(args, "-types="+q.Types)
+	}
+	if q.Unit != "" {
+		args = append(args, "-unit="+q.Unit)
+	}
+	if q.Metric != "" {
+		args = append(args, "-metric="+q.Metric)
+	}
+	if q.Range != "" {
+		start, end, err := relativeRange(q.Range, now)
+		if err != nil {
+			return nil, fmt.Errorf("query %q: %v", name, err)
+		}
+		args = append(args, "-start="+start.Format("2006-01-02"), "-end="+end.Format("2006-01-02"))
+	}
+	return args, nil
+}
+
+// relativeRange resolves a range style such as "last-30-days", "this-year"
+// or "last-year" to the first and last days it covers.
+func relativeRange(style string, now time.Time) (time.Time, time.Time, error) {
+	switch style {
+	case "this-year":
+		return time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location()), now, nil
+	case "last-year":
+		return time.Date(now.Year()-1, 1, 1, 0, 0, 0, 0, now.Location()),
+			time.Date(now.Year()-1, 12, 31, 0, 0, 0, 0, now.Location()), nil
+	}
+	if days := strings.TrimSuffix(strings.TrimPrefix(style, "last-"), "-days"); days != style {
+		n, err := strconv.Atoi(days)
+		if err == nil && n > 0 {
+			return now.AddDate(0, 0, 1-n), now, nil
+		}
+	}
+	return now, now, fmt.Errorf("unknown range %q", style)
+}
+
+// argValue returns the value of -name in args without parsing the rest, so a
+// preset can be expanded before the real parse.
+func argValue(args []string, name string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if arg == name && i+1 < len(args) {
+			return args[i+1], true
+		}
+		if v, ok := strings.CutPrefix(arg, name+"="); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wcharczuk/go-chart"
+)
+
+// graph is anything go-chart can render: a Chart, BarChart, PieChart and so on.
+type graph interface {
+	Render(rp chart.RendererProvider, w io.Writer) error
+}
+
+// renderers maps the -format values go-chart can draw natively.
+var renderers = map[string]chart.RendererProvider{
+	"svg": chart.SVG,
+	"png": chart.PNG,
+}
+
+// postEncoders are the -format values go-chart can't draw, which are
+// rendered to PNG in memory and re-encoded. Adding a format is an entry here
+// plus its extension and MIME type.
+var postEncoders = map[string]func(w io.Writer, img image.Image) error{
+	"jpeg": func(w io.Writer, img image.Image) error {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+	},
+	"webp": encodeWebP,
+}
+
+// extensions maps every -format value to the file extension written by -out.
+var extensions = map[string]string{
+	"svg":     ".svg",
+	"png":     ".png",
+	"jpeg":    ".jpg",
+	"webp":    ".webp",
+	"datauri": ".txt",
+}
+
+var mimeTypes = map[string]string{
+	"svg":  "image/svg+xml",
+	"png":  "image/png",
+	"jpeg": "image/jpeg",
+	"webp": "image/webp",
+}
+
+// render draws graph to w in the given format. The datauri format renders
+// in memory using dataURIFormat then writes a base64 data URI instead.
+func render(graph graph, format, dataURIFormat string, w io.Writer) error {
+	if format == "datauri" {
+		return renderDataURI(graph, dataURIFormat, w)
+	}
+	if encode, ok := postEncoders[format]; ok {
+		return renderPostEncoded(graph, encode, w)
+	}
+	rp, ok := renderers[format]
+	if !ok {
+		return fmt.Errorf("unknown format: %q", format)
+	}
+	return graph.Render(rp, w)
+}
+
+// renderPostEncoded renders graph as PNG and re-encodes it with encode,
+// flattened onto white since not every format has transparency.
+func renderPostEncoded(graph graph, encode func(io.Writer, image.Image) error, w io.Writer) error {
+	var buf bytes.Buffer
+	if err := graph.Render(chart.PNG, &buf); err != nil {
+		return err
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		return err
+	}
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	return encode(w, flat)
+}
+
+func renderDataURI(graph graph, format string, w io.Writer) error {
+	if _, ok := mimeTypes[format]; !ok {
+		return fmt.Errorf("unknown data URI format: %q", format)
+	}
+	var buf bytes.Buffer
+	if err := render(graph, format, "", &buf); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "data:%s;base64,%s\n", mimeTypes[format], base64.StdEncoding.EncodeToString(buf.Bytes()))
+	return err
+}
+
+// writeOutput renders graph in format to stdout, or to the -out path with its
+// extension swapped for the format's own.
+func writeOutput(graph graph, opts Options, format string) error {
+	write := func(w io.Writer) error {
+		if format != "svg" || !opts.animate {
+			return render(graph, format, opts.dataURIFormat, w)
+		}
+		var buf bytes.Buffer
+		if err := render(graph, format, opts.dataURIFormat, &buf); err != nil {
+			return err
+		}
+		_, err := w.Write(animateSVG(buf.Bytes()))
+		return err
+	}
+	if opts.out == "" {
+		return write(os.Stdout)
+	}
+	path := strings.TrimSuffix(opts.out, filepath.Ext(opts.out)) + extensions[format]
+	return writeFileAtomic(path, write)
+}
+
+// writePerMonth renders a chart of each month in the range for -per-month,
+// named after -out and the month, such as ride-2021-01.svg, or just
+// 2021-01.svg when -out is a directory.
+func writePerMonth(opts Options, groups []series) error {
+	prefix := strings.TrimSuffix(opts.out, filepath.Ext(opts.out)) + "-"
+	if info, err := os.Stat(opts.out); (err == nil && info.IsDir()) || strings.HasSuffix(opts.out, string(filepath.Separator)) {
+		prefix = opts.out + string(filepath.Separator)
+	}
+	for month := monthStart(opts.start.In(opts.location)); month.Before(opts.end); month = month.AddDate(0, 1, 0) {
+		monthOpts := opts
+		monthOpts.start = month
+		if monthOpts.start.Before(opts.start) {
+			monthOpts.start = opts.start
+		}
+		// ends are inclusive, as parseDateRange makes them
+		monthOpts.end = month.AddDate(0, 1, 0).Add(-time.Millisecond)
+		if monthOpts.end.After(opts.end) {
+			monthOpts.end = opts.end
+		}
+		monthOpts.out = filepath.Clean(prefix + month.Format("2006-01"))
+
+		var monthGroups []series
+		for _, g := range groups {
+			g.activities = activitiesBetween(g.activities, monthOpts.start, monthOpts.end)
+			monthGroups = append(monthGroups, g)
+		}
+		graph, err := buildGraph(monthOpts, monthGroups)
+		if err != nil {
+			return err
+		}
+		for _, format := range opts.formats {
+			if err := writeOutput(graph, monthOpts, format); err != nil {
+				return fmt.Errorf("error rending %s graph: %v", month.Format("2006-01"), err)
+			}
+		}
+	}
+	return nil
+}
+
+// activitiesBetween returns the activities from start to end inclusive.
+func activitiesBetween(activities Activities, start, end time.Time) Activities {
+	var between Activities
+	for _, a := range activities {
+		if !a.Date.Before(start) && !a.Date.After(end) {
+			between = append(between, a)
+		}
+	}
+	return between
+}
+
+// writeFileAtomic writes to a temporary file beside path and renames it into
+// place, so readers such as a dashboard never see a half-written file.
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
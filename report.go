@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"io"
+)
+
+//go:embed report.html.tmpl
+var reportTemplateText string
+
+var reportTemplate = template.Must(template.New("report").Parse(reportTemplateText))
+
+// reportTotals is one row of the report's summary table.
+type reportTotals struct {
+	Name     string
+	Count    int
+	Distance string
+	Duration int64
+}
+
+// reportData is everything the report template draws.
+type reportData struct {
+	Title    string
+	Chart    template.HTML
+	Distance string
+	Types    []reportTotals
+	Total    reportTotals
+	Header   []string
+	Rows     [][]string
+	Version  string
+}
+
+// writeReport writes a self-contained HTML page with graph inlined as SVG,
+// the summary stats and a table of the activities.
+func writeReport(w io.Writer, graph graph, activities Activities, opts Options) error {
+	var svg bytes.Buffer
+	if err := render(graph, "svg", opts.dataURIFormat, &svg); err != nil {
+		return err
+	}
+
+	s := computeStats(activities, opts.unit)
+	data := reportData{
+		Title:    "Activities " + opts.start.Format("2006-01-02") + " to " + opts.end.Format("2006-01-02"),
+		Chart:    template.HTML(svg.String()),
+		Distance: units[opts.unit].distance,
+		Total: reportTotals{
+			Name:     "Total",
+			Count:    s.count,
+			Distance: formatDistance(s.totalDistance, opts),
+			Duration: s.totalDuration,
+		},
+		Header:  tableHeader(opts),
+		Version: versionString(),
+	}
+	for _, id := range s.types() {
+		t := s.byType[id]
+		data.Types = append(data.Types, reportTotals{
+			Name:     activityTypeName(id),
+			Count:    t.count,
+			Distance: formatDistance(t.distance, opts),
+			Duration: t.duration,
+		})
+	}
+	for _, a := range textOrder(activities, opts) {
+		data.Rows = append(data.Rows, tableRow(a, opts))
+	}
+	return reportTemplate.Execute(w, data)
+}
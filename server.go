@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// serverOnlyFlags can't be overridden from a query string.
+var serverOnlyFlags = map[string]bool{
+	"serve":               true,
+	"cache-size":          true,
+	"cache-ttl":           true,
+	"config-dir":          true,
+	"out":                 true,
+	"log-format":          true,
+	"rps":                 true,
+	"buckets-file":        true,
+	"service-account":     true,
+	"users":               true,
+	"watch":               true,
+	"influx":              true,
+	"prom":                true,
+	"client-secret-stdin": true,
+	"insecure":            true,
+	"ca-bundle":           true,
+	"notes-file":          true,
+	"report":              true,
+	"colors-file":         true,
+	"cpuprofile":          true,
+	"memprofile":          true,
+	"oauth-port":          true,
+	"tsv":                 true,
+	"manual-auth":         true,
+	"json":                true,
+	"from-json":           true,
+	"csv":                 true,
+	"ics":                 true,
+	"import":              true,
+	"dump-responses":      true,
+	"per-month":           true,
+}
+
+// serve renders a chart for every request. Query parameters are treated as
+// extra flags on top of the command line, e.g. /?metric=effort&format=png.
+func serve(opts Options, client *http.Client) error {
+	cache := newRenderCache(opts.cacheSize, opts.cacheTTL)
+	fetches := newRenderCache(opts.cacheSize, opts.cacheTTL)
+	// load fetches or reuses the activities for reqOpts' range, then prepares
+	// a copy, as preparing may sort it under another request
+	load := func(reqOpts Options) (Activities, error) {
+		var fetched Activities
+		if v, ok := fetches.get(fetchKey(reqOpts)); ok {
+			fetched = v.(Activities)
+		} else {
+			var err error
+			if fetched, err = sourceActivities(reqOpts, client, "me"); err != nil {
+				return nil, err
+			}
+			fetches.add(fetchKey(reqOpts), fetched)
+		}
+		return prepareActivities(reqOpts, append(Activities(nil), fetched...)), nil
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		args := append([]string(nil), os.Args[1:]...)
+		for name, values := range r.URL.Query() {
+			if serverOnlyFlags[name] {
+				http.Error(w, fmt.Sprintf("%s can't be set per request", name), http.StatusBadRequest)
+				return
+			}
+			for _, v := range values {
+				args = append(args, fmt.Sprintf("-%s=%s", name, v))
+			}
+		}
+		reqOpts, err := parseOptions(args, io.Discard)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		format := reqOpts.formats[0]
+
+		key := cacheKey(reqOpts, format)
+		cached, ok := cache.get(key)
+		data, _ := cached.([]byte)
+		if !ok {
+			activities, err := load(reqOpts)
+			if err != nil {
+				log.Printf("%v\n", err)
+				http.Error(w, "error fetching activities", http.StatusBadGateway)
+				return
+			}
+			groups := []series{{activities: activities}}
+			if reqOpts.ghost != "" {
+				prev := reqOpts
+				var shift time.Duration
+				prev.start, prev.end, shift = ghostRange(reqOpts)
+				prev.minSessions = 0
+				ghost, err := load(prev)
+				if err != nil {
+					log.Printf("%v\n", err)
+					http.Error(w, "error fetching activities", http.StatusBadGateway)
+					return
+				}
+				groups = withGhosts([]series{shiftedGhost("", ghost, shift)}, groups)
+			}
+			graph, err := buildGraph(reqOpts, groups)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var buf bytes.Buffer
+			if err := render(graph, format, reqOpts.dataURIFormat, &buf); err != nil {
+				log.Printf("error rending graph: %v\n", err)
+				http.Error(w, "error rendering graph", http.StatusInternalServerError)
+				return
+			}
+			data = buf.Bytes()
+			if format == "svg" && reqOpts.animate {
+				data = animateSVG(data)
+			}
+			cache.add(key, data)
+		}
+
+		contentType, ok := mimeTypes[format]
+		if !ok {
+			contentType = "text/plain; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(data)
+	})
+
+	infof("serving charts on %s\n", opts.serve)
+	return http.ListenAndServe(opts.serve, nil)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/wcharczuk/go-chart"
+	"google.golang.org/api/fitness/v1"
+	"google.golang.org/api/option"
+)
+
+// fetchSession is fetchActivities for -session. It finds the session with
+// that ID in the query range and aggregates it in -session-bucket slices
+// rather than as a whole, returning one Activity per slice so the usual
+// metrics chart its distance or speed over the session.
+func fetchSession(opts Options, client *http.Client, userID string) (Activities, error) {
+	fitnessService, err := fitness.NewService(context.TODO(), option.WithHTTPClient(client))
+	if err != nil {
+		return nil, err
+	}
+	fitnessService.UserAgent = opts.userAgent
+	sessionService := fitness.NewUsersSessionsService(fitnessService)
+	datasetService := fitness.NewUsersDatasetService(fitnessService)
+
+	var session *fitness.Session
+	for _, r := range chunkRanges(opts.start, opts.end, opts.chunk) {
+		// any type, since the ID already says which session
+		chunk, err := listSessions(sessionService, userID, nil, r[0], r[1])
+		if err != nil {
+			return nil, fmt.Errorf("error listing sessions: %v", err)
+		}
+		for _, s := range chunk {
+			if s != nil && s.Id == opts.session {
+				session = s
+			}
+		}
+		if session != nil {
+			break
+		}
+	}
+	if session == nil {
+		return nil, fmt.Errorf("%w: no session with ID %q from %s to %s",
+			errNoData, opts.session, opts.start.Format("2006-01-02"), opts.end.Format("2006-01-02"))
+	}
+
+	aggregates := []*fitness.AggregateBy{{DataTypeName: "com.google.distance.delta"}}
+	if usesMetric(opts, "heart-rate") {
+		aggregates = append(aggregates, &fitness.AggregateBy{DataTypeName: "com.google.heart_rate.bpm"})
+	}
+	r, err := datasetService.Aggregate(userID, &fitness.AggregateRequest{
+		AggregateBy:     aggregates,
+		BucketByTime:    &fitness.BucketByTime{DurationMillis: opts.sessionBucket.Milliseconds()},
+		StartTimeMillis: session.StartTimeMillis,
+		EndTimeMillis:   session.EndTimeMillis,
+	}).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error getting dataset: %v", err)
+	}
+
+	// bucketActivity checks -types, which the session already passed
+	sliceOpts := opts
+	sliceOpts.types = nil
+	var activities Activities
+	for _, bucket := range r.Bucket {
+		if bucket == nil || implausibleTimes(bucket, opts.start, opts.end) != "" {
+			continue
+		}
+		activity, _ := bucketActivity(sliceOpts, session, bucket)
+		activities = append(activities, activity)
+	}
+	slog.Info("aggregated session", "session", session.Name, "slices", len(activities))
+	return activities, nil
+}
+
+// sessionTicks labels the X axis of a -session chart with the time of day,
+// every few minutes so there are at most about a dozen ticks.
+func sessionTicks(start, end time.Time) []chart.Tick {
+	step := time.Minute
+	for _, s := range []time.Duration{2, 5, 10, 15, 30, 60, 120} {
+		if end.Sub(start)/step <= 12 {
+			break
+		}
+		step = s * time.Minute
+	}
+	var ticks []chart.Tick
+	for t := start.Truncate(step); !t.After(end.Add(step)); t = t.Add(step) {
+		ticks = append(ticks, chart.Tick{Value: float64(t.Unix()), Label: t.Format("15:04")})
+	}
+	return ticks
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"time"
+
+	"github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// weekendShade is a chart series that shades every Saturday and Sunday from
+// start to end. It has no values, so it doesn't affect the axis ranges, and
+// it goes first in the chart's series so the data is drawn over it.
+type weekendShade struct {
+	start, end time.Time
+}
+
+var weekendStyle = chart.Style{
+	StrokeColor: drawing.Color{R: 220, G: 220, B: 220, A: 255},
+	FillColor:   drawing.Color{R: 235, G: 235, B: 235, A: 160},
+}
+
+func (weekendShade) GetName() string           { return "Weekends" }
+func (weekendShade) GetYAxis() chart.YAxisType { return chart.YAxisPrimary }
+func (weekendShade) GetStyle() chart.Style     { return weekendStyle }
+func (weekendShade) Validate() error           { return nil }
+
+// Render draws a band from each Saturday to the following Monday, positioned
+// with the same X range as the data and clipped to the canvas.
+func (w weekendShade) Render(r chart.Renderer, canvasBox chart.Box, xrange, _ chart.Range, _ chart.Style) {
+	day := time.Date(w.start.Year(), w.start.Month(), w.start.Day(), 0, 0, 0, 0, w.start.Location())
+	saturday := day.AddDate(0, 0, (int(time.Saturday)-int(day.Weekday())+7)%7)
+	// a range starting on Sunday still shades that Sunday
+	if day.Weekday() == time.Sunday {
+		saturday = day.AddDate(0, 0, -1)
+	}
+	style := weekendStyle
+	style.StrokeWidth = chart.Disabled
+	for ; saturday.Before(w.end); saturday = saturday.AddDate(0, 0, 7) {
+		left := canvasBox.Left + xrange.Translate(float64(saturday.Unix()))
+		right := canvasBox.Left + xrange.Translate(float64(saturday.AddDate(0, 0, 2).Unix()))
+		if left < canvasBox.Left {
+			left = canvasBox.Left
+		}
+		if right > canvasBox.Right {
+			right = canvasBox.Right
+		}
+		if right <= left {
+			continue
+		}
+		chart.Draw.Box(r, chart.Box{Top: canvasBox.Top, Left: left, Right: right, Bottom: canvasBox.Bottom}, style)
+	}
+}
+
+// spanShade is a chart series that shades from one time to another, such as
+// the current streak. Like weekendShade it goes in front of the data series.
+type spanShade struct {
+	name     string
+	from, to time.Time
+	style    chart.Style
+}
+
+var streakStyle = chart.Style{
+	StrokeColor: drawing.Color{R: 255, G: 220, B: 0, A: 255},
+	FillColor:   drawing.Color{R: 255, G: 220, B: 0, A: 64},
+}
+
+func (s spanShade) GetName() string         { return s.name }
+func (spanShade) GetYAxis() chart.YAxisType { return chart.YAxisPrimary }
+func (s spanShade) GetStyle() chart.Style   { return s.style }
+func (spanShade) Validate() error           { return nil }
+
+// Render draws the span, clipped to the canvas.
+func (s spanShade) Render(r chart.Renderer, canvasBox chart.Box, xrange, _ chart.Range, _ chart.Style) {
+	left := canvasBox.Left + xrange.Translate(float64(s.from.Unix()))
+	right := canvasBox.Left + xrange.Translate(float64(s.to.Unix()))
+	if left < canvasBox.Left {
+		left = canvasBox.Left
+	}
+	if right > canvasBox.Right {
+		right = canvasBox.Right
+	}
+	if right <= left {
+		return
+	}
+	style := s.style
+	style.StrokeWidth = chart.Disabled
+	chart.Draw.Box(r, chart.Box{Top: canvasBox.Top, Left: left, Right: right, Bottom: canvasBox.Bottom}, style)
+}
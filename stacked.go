@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// buildStackedChart draws the metric per -bucket period (weeks by default) as
+// one band per activity type, stacked so the top edge is the total. With
+// -cumulative each band is its type's running total.
+func buildStackedChart(opts Options, activities Activities) *chart.Chart {
+	period := opts.bucket
+	if period == "" {
+		period = "week"
+	}
+	buckets := periodBuckets(period, opts.start, opts.end, opts.weekStart)
+
+	byType := map[int64]Activities{}
+	for _, a := range activities {
+		byType[a.ActivityType] = append(byType[a.ActivityType], a)
+	}
+	var ids []int64
+	for id := range byType {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	xs := make([]float64, len(buckets))
+	for i, b := range buckets {
+		xs[i] = float64(b.start.Unix())
+	}
+	// stacks[i] is the top edge of the i'th type's band
+	stacks := make([][]float64, len(ids))
+	below := make([]float64, len(buckets))
+	for i, id := range ids {
+		sums := sumBuckets(opts, byType[id], buckets)
+		total := 0.0
+		stacks[i] = make([]float64, len(buckets))
+		for j, sum := range sums {
+			total += sum
+			if opts.cumulative {
+				sum = total
+			}
+			below[j] += sum
+			stacks[i][j] = below[j]
+		}
+	}
+
+	maxY := 0.0
+	for _, y := range below {
+		maxY = math.Max(maxY, y)
+	}
+
+	// go-chart fills each area down to zero, so draw the tallest band first
+	// and let the ones below paint over it
+	var bands []chart.Series
+	for i := len(ids) - 1; i >= 0; i-- {
+		color := typeColor(opts.colors, ids[i])
+		bands = append(bands, chart.ContinuousSeries{
+			Name: activityTypeName(ids[i]),
+			Style: chart.Style{
+				StrokeColor: color,
+				FillColor:   color,
+			},
+			XValues: xs,
+			YValues: stacks[i],
+		})
+	}
+	if len(bands) == 0 {
+		// go-chart refuses to draw a chart without series
+		bands = append(bands, chart.ContinuousSeries{
+			Name:    "No activities",
+			Style:   chart.Style{StrokeColor: drawing.ColorFromHex("aaaaaa")},
+			XValues: xs,
+			YValues: make([]float64, len(xs)),
+		})
+	}
+
+	yLabel := metrics[opts.metric].label(opts)
+	if opts.yLabel != "" {
+		yLabel = opts.yLabel
+	}
+	xLabel := "Date"
+	if opts.xLabel != "" {
+		xLabel = opts.xLabel
+	}
+	first := time.Date(opts.start.Year(), opts.start.Month(), 1, 0, 0, 0, 0, opts.location)
+	ticks := yTicks(maxY, opts.yTickCount, opts.precision)
+	graph := &chart.Chart{
+		YAxis: chart.YAxis{
+			Name:  yLabel,
+			Ticks: ticks,
+			Range: &chart.ContinuousRange{Min: 0, Max: ticks[len(ticks)-1].Value},
+		},
+		XAxis: chart.XAxis{
+			Name:  xLabel,
+			Ticks: monthTicks(first, monthsBetween(first, opts.end)+1, opts.monthStep),
+		},
+		Series: bands,
+	}
+	applyLayout(opts, graph)
+	graph.Elements = []chart.Renderable{chart.Legend(graph)}
+	if opts.annotateActiveDays {
+		graph.Elements = append(graph.Elements, activeDaysElement(opts, activities, &graph.Background.Padding))
+	}
+	return graph
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestStackedChartWithoutActivities(t *testing.T) {
+	opts := Options{
+		metric:     "distance",
+		unit:       "mi",
+		start:      time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		end:        time.Date(2021, 3, 31, 23, 59, 59, 0, time.UTC),
+		location:   time.UTC,
+		weekStart:  time.Monday,
+		yTickCount: 10,
+		monthStep:  1,
+		padding:    -1,
+	}
+	if err := render(buildStackedChart(opts, nil), "svg", "", io.Discard); err != nil {
+		t.Fatalf("rendering an empty stacked chart: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import "sort"
+
+// stats are the summary numbers computed over a set of activities.
+type stats struct {
+	count         int
+	totalDistance float64
+	totalDuration int64
+	byType        map[int64]*typeStats
+}
+
+// typeStats are the totals for one activity type.
+type typeStats struct {
+	count    int
+	distance float64
+	duration int64
+}
+
+// computeStats totals the activities, converting distances into unit.
+func computeStats(activities Activities, unit string) stats {
+	s := stats{byType: map[int64]*typeStats{}}
+	for _, a := range activities {
+		distance := convertDistance(a.Distance, unit)
+		s.count++
+		s.totalDistance += distance
+		s.totalDuration += a.Duration
+
+		t, ok := s.byType[a.ActivityType]
+		if !ok {
+			t = &typeStats{}
+			s.byType[a.ActivityType] = t
+		}
+		t.count++
+		t.distance += distance
+		t.duration += a.Duration
+	}
+	return s
+}
+
+// types returns the activity types present, in ID order so output is stable.
+func (s stats) types() []int64 {
+	var ids []int64
+	for id := range s.byType {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
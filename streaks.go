@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// streak is a run of consecutive periods with at least one activity, from
+// the period starting at start to the one starting at last.
+type streak struct {
+	length      int
+	start, last time.Time
+}
+
+// dayStart truncates t to midnight in its own location.
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// weekStart truncates t to midnight on the first day of its week, where
+// weeks start on first.
+func weekStart(t time.Time, first time.Weekday) time.Time {
+	d := dayStart(t)
+	return d.AddDate(0, 0, -(int(d.Weekday())-int(first)+7)%7)
+}
+
+// findStreaks returns the longest streak of periods holding an activity and
+// the current one, which must reach the period containing ref or the one just
+// before it, since today may not have had its workout yet. period truncates a
+// time to the start of its period and next steps to the following one.
+func findStreaks(activities Activities, ref time.Time, period func(time.Time) time.Time, next func(time.Time) time.Time) (longest, current streak) {
+	var run streak
+	for _, a := range activities {
+		p := period(a.Date)
+		switch {
+		case run.length > 0 && p.Equal(run.last):
+			continue
+		case run.length > 0 && p.Equal(next(run.last)):
+			run.length++
+			run.last = p
+		default:
+			run = streak{length: 1, start: p, last: p}
+		}
+		if run.length > longest.length {
+			longest = run
+		}
+	}
+	refPeriod := period(ref)
+	if run.length > 0 && (run.last.Equal(refPeriod) || next(run.last).Equal(refPeriod)) {
+		current = run
+	}
+	return longest, current
+}
+
+// dailyStreaks finds streaks of days, with ref as today.
+func dailyStreaks(activities Activities, ref time.Time) (longest, current streak) {
+	return findStreaks(activities, ref, dayStart, func(t time.Time) time.Time { return t.AddDate(0, 0, 1) })
+}
+
+// weeklyStreaks finds streaks of weeks starting on first, with ref as today.
+func weeklyStreaks(activities Activities, ref time.Time, first time.Weekday) (longest, current streak) {
+	week := func(t time.Time) time.Time { return weekStart(t, first) }
+	return findStreaks(activities, ref, week, func(t time.Time) time.Time { return t.AddDate(0, 0, 7) })
+}
+
+// streakRef is the day the current streak is measured up to: today, or the
+// end of the range when that has already passed.
+func streakRef(opts Options, now time.Time) time.Time {
+	if now.After(opts.end) {
+		return opts.end
+	}
+	return now.In(opts.location)
+}
+
+// writeStats prints the summary stats and streaks for -stats.
+func writeStats(w io.Writer, activities Activities, opts Options, now time.Time) error {
+	s := computeStats(activities, opts.unit)
+	distance := units[opts.unit].distance
+	fmt.Fprintf(w, "Activities: %d\n", s.count)
+	fmt.Fprintf(w, "%s: %s\n", distance, formatDistance(s.totalDistance, opts))
+	fmt.Fprintf(w, "Duration (min): %d\n", s.totalDuration)
+	for _, id := range s.types() {
+		t := s.byType[id]
+		fmt.Fprintf(w, "  %s: %d activities, %s %s, %d min\n",
+			activityTypeName(id), t.count, formatDistance(t.distance, opts), distance, t.duration)
+	}
+
+	ref := streakRef(opts, now)
+	longest, current := dailyStreaks(activities, ref)
+	fmt.Fprintf(w, "Longest streak: %s\n", describeStreak(longest, "day"))
+	fmt.Fprintf(w, "Current streak: %s\n", describeStreak(current, "day"))
+	longest, current = weeklyStreaks(activities, ref, opts.weekStart)
+	fmt.Fprintf(w, "Longest weekly streak: %s\n", describeStreak(longest, "week"))
+	fmt.Fprintf(w, "Current weekly streak: %s\n", describeStreak(current, "week"))
+
+	start := opts.start.In(opts.location)
+	week := func(t time.Time) time.Time { return weekStart(t, opts.weekStart) }
+	active, total := activePeriods(activities, start, ref, week, func(t time.Time) time.Time { return t.AddDate(0, 0, 7) })
+	fmt.Fprintf(w, "Active weeks: %s\n", describeConsistency(active, total))
+	active, total = activePeriods(activities, start, ref, monthStart, func(t time.Time) time.Time { return t.AddDate(0, 1, 0) })
+	_, err := fmt.Fprintf(w, "Active months: %s\n", describeConsistency(active, total))
+	if err != nil || !opts.trend {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "Trend: %s\n", describeTrend(opts, activities))
+	return err
+}
+
+// describeTrend prints the -trend slope of the metric per week, such as
+// "+0.3 mi/week".
+func describeTrend(opts Options, activities Activities) string {
+	slope, _, ok := linearFit(metricValues(opts, activities))
+	if !ok {
+		return "not enough activities"
+	}
+	perWeek := slope * (7 * 24 * time.Hour).Seconds()
+	sign := "+"
+	if perWeek < 0 {
+		sign = "-"
+	}
+	return fmt.Sprintf("%s%s %s/week", sign, formatNumber(math.Abs(perWeek), opts.precision), metricUnit(opts))
+}
+
+// monthStart truncates t to midnight on the first of its month.
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// activePeriods counts the periods from the one containing start to the one
+// containing ref, and how many of them hold at least one activity. Periods
+// after ref haven't happened yet, so they count towards neither.
+func activePeriods(activities Activities, start, ref time.Time, period func(time.Time) time.Time, next func(time.Time) time.Time) (active, total int) {
+	seen := map[time.Time]bool{}
+	for _, a := range activities {
+		// map keys compare locations too, so bring every date into start's
+		seen[period(a.Date.In(start.Location()))] = true
+	}
+	for p := period(start); !p.After(ref); p = next(p) {
+		total++
+		if seen[p] {
+			active++
+		}
+	}
+	return active, total
+}
+
+// describeConsistency prints active out of total periods and the percentage.
+func describeConsistency(active, total int) string {
+	if total == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("%d/%d (%d%%)", active, total, active*100/total)
+}
+
+// describeStreak prints a streak's length in units and its dates.
+func describeStreak(s streak, unit string) string {
+	if s.length == 0 {
+		return "none"
+	}
+	if s.length != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s (from %s)", s.length, unit, s.start.Format("2006-01-02"))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// tableHeader returns the column names shared by -table and -report.
+func tableHeader(opts Options) []string {
+	return []string{"Date", "Type", units[opts.unit].distance, "Duration (min)"}
+}
+
+// tableRow returns one activity's cells under tableHeader.
+func tableRow(a Activity, opts Options) []string {
+	return []string{
+		a.Date.Format("2006-01-02 15:04"),
+		activityTypeName(a.ActivityType),
+		formatDistance(convertDistance(a.Distance, opts.unit), opts),
+		formatMinutes(a.Duration),
+	}
+}
+
+// writeTable prints activities as an aligned text table for a quick look at
+// the data without rendering a chart.
+func writeTable(w io.Writer, activities Activities, opts Options) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(tableHeader(opts), "\t"))
+	for _, a := range textOrder(activities, opts) {
+		fmt.Fprintln(tw, strings.Join(tableRow(a, opts), "\t"))
+	}
+	return tw.Flush()
+}
+
+// formatMinutes prints a duration in minutes for the tables.
+func formatMinutes(minutes int64) string {
+	return strconv.FormatInt(minutes, 10)
+}
+
+// textOrder returns activities in the -sort order for textual outputs. The
+// chart always stays chronological.
+func textOrder(activities Activities, opts Options) Activities {
+	if opts.sortOrder != "desc" {
+		return activities
+	}
+	reversed := make(Activities, len(activities))
+	for i, a := range activities {
+		reversed[len(activities)-1-i] = a
+	}
+	return reversed
+}
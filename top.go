@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// topActivities returns the n activities with the most of the metric, most
+// first.
+func topActivities(opts Options, activities Activities, n int) Activities {
+	m := metrics[opts.metric]
+	top := append(Activities(nil), activities...)
+	sort.SliceStable(top, func(i, j int) bool {
+		return m.value(top[i], opts) > m.value(top[j], opts)
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// topChart draws one labelled horizontal bar per activity for -chart top-n.
+// go-chart's BarChart is vertical only, so it draws on the renderer itself.
+type topChart struct {
+	title    string
+	labels   []string
+	values   []float64
+	unit     string
+	opts     Options
+	barColor drawing.Color
+}
+
+// buildTopChart charts the -n activities with the most of the metric.
+func buildTopChart(opts Options, activities Activities) topChart {
+	m := metrics[opts.metric]
+	unit := metricUnit(opts)
+	title := fmt.Sprintf("Top %d activities by %s", opts.topN, strings.ToLower(m.label(opts)))
+	if opts.yLabel != "" {
+		title = opts.yLabel
+	}
+	c := topChart{title: title, unit: unit, opts: opts, barColor: chart.GetDefaultColor(0)}
+	for _, a := range topActivities(opts, activities, opts.topN) {
+		label := a.Date.In(opts.location).Format("2006-01-02")
+		if a.Name != "" {
+			label += " " + a.Name
+		}
+		c.labels = append(c.labels, label)
+		c.values = append(c.values, m.value(a, opts))
+	}
+	return c
+}
+
+// Render implements graph.
+func (c topChart) Render(rp chart.RendererProvider, w io.Writer) error {
+	const rowHeight, barHeight = 28, 18
+	fontSize := 10.0
+	if c.opts.fontSize > 0 {
+		fontSize = c.opts.fontSize
+	}
+	padding := 20
+	if c.opts.padding >= 0 {
+		padding = c.opts.padding
+	}
+	width := c.opts.width
+	if width == 0 {
+		width = chart.DefaultChartWidth
+	}
+	height := c.opts.height
+	if height == 0 {
+		height = 2*padding + 2*rowHeight + len(c.values)*rowHeight
+	}
+
+	r, err := rp(width, height)
+	if err != nil {
+		return err
+	}
+	font, err := chart.GetDefaultFont()
+	if err != nil {
+		return err
+	}
+	text := chart.Style{Font: font, FontSize: fontSize, FontColor: chart.DefaultTextColor}
+	chart.Draw.Box(r, chart.Box{Right: width, Bottom: height}, chart.Style{FillColor: drawing.ColorWhite, StrokeColor: drawing.ColorWhite})
+	chart.Draw.Text(r, c.title, padding, padding+rowHeight/2, chart.Style{Font: font, FontSize: fontSize * 1.4, FontColor: chart.DefaultTextColor})
+
+	labelWidth, valueWidth := 0, 0
+	max := 0.0
+	text.WriteToRenderer(r)
+	for i, v := range c.values {
+		labelWidth = int(math.Max(float64(labelWidth), float64(r.MeasureText(c.labels[i]).Width())))
+		valueWidth = int(math.Max(float64(valueWidth), float64(r.MeasureText(c.valueLabel(v)).Width())))
+		max = math.Max(max, v)
+	}
+	barLeft := padding + labelWidth + 10
+	barSpace := width - barLeft - valueWidth - 10 - padding
+	for i, v := range c.values {
+		top := padding + 2*rowHeight + i*rowHeight
+		baseline := top + barHeight/2 + int(fontSize/2)
+		chart.Draw.Text(r, c.labels[i], padding, baseline, text)
+		length := 0
+		if max > 0 {
+			length = int(v / max * float64(barSpace))
+		}
+		chart.Draw.Box(r, chart.Box{Left: barLeft, Top: top, Right: barLeft + length, Bottom: top + barHeight},
+			chart.Style{FillColor: c.barColor, StrokeColor: c.barColor})
+		chart.Draw.Text(r, c.valueLabel(v), barLeft+length+10, baseline, text)
+	}
+	return r.Save(w)
+}
+
+// valueLabel formats a bar's value with its unit, such as "42.1 mi".
+func (c topChart) valueLabel(v float64) string {
+	if c.opts.metric == "distance" {
+		return groupThousands(formatDistance(v, c.opts)) + " " + c.unit
+	}
+	return formatThousands(v, c.opts.precision) + " " + c.unit
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// httpContext returns a context carrying the *http.Client that both the OAuth
+// and service-account clients build their transport on.
+func httpContext(opts Options) context.Context {
+	ctx := context.Background()
+	repeated := opts.serve != "" || opts.watch > 0
+	if !opts.insecure && opts.caBundle == "" && opts.dumpResponses == "" && !repeated {
+		return ctx
+	}
+
+	var transport http.RoundTripper = http.DefaultTransport
+	if opts.insecure || opts.caBundle != "" {
+		transport = tlsTransport(opts)
+	}
+	if repeated {
+		transport = &conditionalTransport{base: transport, cached: newRenderCache(conditionalEntries, 24*time.Hour)}
+	}
+	if opts.dumpResponses != "" {
+		if err := os.MkdirAll(opts.dumpResponses, 0700); err != nil {
+			log.Fatalf("unable to create -dump-responses directory: %v\n", err)
+		}
+		transport = &dumpTransport{base: transport, dir: opts.dumpResponses}
+	}
+	return context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: transport})
+}
+
+// tlsTransport is the default transport trusting -ca-bundle, or nothing at
+// all with -insecure.
+func tlsTransport(opts Options) *http.Transport {
+	tlsConfig := &tls.Config{}
+	if opts.caBundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(opts.caBundle)
+		if err != nil {
+			log.Fatalf("unable to read -ca-bundle: %v\n", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			log.Fatalf("no certificates found in -ca-bundle %s\n", opts.caBundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	tlsConfig.InsecureSkipVerify = opts.insecure
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport
+}
+
+// warnInsecure warns that -insecure turned verification off. run calls it on
+// every render, so each -watch pass repeats it, and -serve once at startup.
+func warnInsecure(opts Options) {
+	if opts.insecure {
+		slog.Warn("-insecure is set, TLS certificates are NOT being verified. " +
+			"Anyone on the network path can read and alter traffic, including your Google credentials.")
+	}
+}
+
+// dumpTransport writes the body of every Fit API response to a numbered file
+// in dir for -dump-responses. Token responses go through the same transport
+// but are skipped, so the files are safe to attach to a bug report.
+type dumpTransport struct {
+	base http.RoundTripper
+	dir  string
+	n    int64
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *dumpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || !strings.Contains(req.URL.Path, "/fitness/") {
+		return resp, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	kind := "dataset"
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/sessions"):
+		kind = "sessions"
+	case strings.HasSuffix(req.URL.Path, ":aggregate"):
+		kind = "aggregate"
+	}
+	path := filepath.Join(t.dir, fmt.Sprintf("%04d-%s.json", atomic.AddInt64(&t.n, 1), kind))
+	if err := ioutil.WriteFile(path, body, 0600); err != nil {
+		infof("warning: unable to dump response: %v\n", err)
+	}
+	return resp, nil
+}
+
+// conditionalTransport revalidates repeated GETs for -serve and -watch. When a
+// response carries an ETag or Last-Modified header it is kept, and the next
+// identical request sends If-None-Match or If-Modified-Since so an unchanged
+// result comes back as an empty 304 and is answered from memory.
+//
+// The Fit API doesn't document sending either header, and the aggregate
+// queries are POSTs, which can't be revalidated this way. Anything without
+// validators passes straight through, and the time-based fetch cache of
+// -serve is what avoids refetching it.
+type conditionalTransport struct {
+	base   http.RoundTripper
+	cached *renderCache
+}
+
+// conditionalEntries is how many responses conditionalTransport keeps. Each
+// -watch run with a moving -end asks for new URLs, so it has to be bounded.
+const conditionalEntries = 256
+
+type cachedResponse struct {
+	etag, lastModified string
+	header             http.Header
+	body               []byte
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *conditionalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+	// the token is part of the key so accounts sharing the transport, which
+	// all fetch "me", never see each other's data
+	key := req.Header.Get("Authorization") + " " + req.URL.String()
+	var cached *cachedResponse
+	if v, ok := t.cached.get(key); ok {
+		cached = v.(*cachedResponse)
+		// RoundTrippers mustn't modify the caller's request
+		req = req.Clone(req.Context())
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		resp.Body.Close()
+		infof("%s unchanged, reusing the previous response\n", req.URL.Path)
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        cached.header.Clone(),
+			Body:          ioutil.NopCloser(bytes.NewReader(cached.body)),
+			ContentLength: int64(len(cached.body)),
+			Request:       req,
+		}, nil
+	}
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (etag == "" && lastModified == "") {
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	t.cached.add(key, &cachedResponse{etag: etag, lastModified: lastModified, header: resp.Header.Clone(), body: body})
+	return resp, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInsecureWarnsEveryRun(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(logger) })
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	out := filepath.Join(t.TempDir(), "chart.svg")
+	opts, err := parseOptions([]string{"-demo", "-insecure", "-out=" + out}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := run(opts, []source{{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(logs.String(), `"level":"WARN","msg":"-insecure is set`); n != 2 {
+		t.Errorf("got %d -insecure warnings in two runs, want 2:\n%s", n, logs.String())
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// unit holds the labels for a -unit value.
+type unit struct {
+	distance string
+	speed    string
+}
+
+var units = map[string]unit{
+	"mi": {"Miles", "mph"},
+	"km": {"Kilometers", "km/h"},
+	"m":  {"Meters", "m/h"},
+}
+
+// Conversion factors from meters, the unit the Fit API reports lengths in.
+const (
+	metersPerMile      = 1609.344
+	metersPerKilometer = 1000
+	metersPerFoot      = 0.3048
+	metersPerYard      = 0.9144
+)
+
+// metersTo converts meters into unit, which is "mi", "km", "ft" or "yd". Any
+// other unit is taken to be meters.
+func metersTo(unit string, meters float64) float64 {
+	switch unit {
+	case "mi":
+		return meters / metersPerMile
+	case "km":
+		return meters / metersPerKilometer
+	case "ft":
+		return meters / metersPerFoot
+	case "yd":
+		return meters / metersPerYard
+	}
+	return meters
+}
+
+// readTypeUnits loads units.json from the config dir, mapping activity type
+// names or IDs to the -unit they are measured in, such as {"swimming": "m"}.
+// A missing file means every type uses -unit.
+func readTypeUnits(configDir string) (map[int64]string, error) {
+	path, err := configFile(configDir, "units.json")
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var byName map[string]string
+	if err := json.Unmarshal(b, &byName); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	typeUnits := map[int64]string{}
+	for name, unit := range byName {
+		id, ok := lookupActivityType(name)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown activity type %q", path, name)
+		}
+		if _, ok := units[unit]; !ok {
+			return nil, fmt.Errorf("%s: unknown unit %q for %s", path, unit, name)
+		}
+		typeUnits[id] = unit
+	}
+	return typeUnits, nil
+}
+
+// convertDistance converts miles into the given -unit.
+func convertDistance(miles float64, unit string) float64 {
+	return metersTo(unit, miles*metersPerMile)
+}
+
+// roundTo rounds v to precision decimal places, rounding halves up.
+func roundTo(v float64, precision int) float64 {
+	var round float64
+	pow := math.Pow(10, float64(precision))
+	digit := pow * v
+	_, div := math.Modf(digit)
+	if div >= 0.5 {
+		round = math.Ceil(digit)
+	} else {
+		round = math.Floor(digit)
+	}
+	return round / pow
+}
+
+// roundDistance rounds a distance to -precision places the -round-mode way,
+// for display and for the CSV and influx exports alike. Only what is written
+// out is rounded; totals are summed at full precision first.
+func roundDistance(v float64, opts Options) float64 {
+	pow := math.Pow(10, float64(opts.precision))
+	// the slack stops 1.1*10 = 11.000000000000002 from rounding up to 1.2
+	switch opts.roundMode {
+	case "floor":
+		return math.Floor(v*pow+1e-9) / pow
+	case "ceil":
+		return math.Ceil(v*pow-1e-9) / pow
+	}
+	return roundTo(v, opts.precision)
+}
+
+// formatNumber prints v rounded to precision decimal places without trailing
+// zeros, so 12.50 prints as 12.5 and 100.00 as 100.
+func formatNumber(v float64, precision int) string {
+	return formatRounded(roundTo(v, precision), precision)
+}
+
+// formatDistance is formatNumber for distances, rounded per -round-mode.
+func formatDistance(v float64, opts Options) string {
+	return formatRounded(roundDistance(v, opts), opts.precision)
+}
+
+// formatRounded prints an already rounded v without trailing zeros.
+func formatRounded(v float64, precision int) string {
+	label := strconv.FormatFloat(v, 'f', precision, 64)
+	if strings.Contains(label, ".") {
+		label = strings.TrimSuffix(strings.TrimRight(label, "0"), ".")
+	}
+	return label
+}
+
+// formatThousands is formatNumber with commas between groups of thousands,
+// so 1842.5 prints as 1,842.5.
+func formatThousands(v float64, precision int) string {
+	return groupThousands(formatNumber(v, precision))
+}
+
+// groupThousands puts commas between the groups of thousands of a printed
+// number.
+func groupThousands(label string) string {
+	sign := ""
+	if strings.HasPrefix(label, "-") {
+		sign, label = "-", label[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(label, ".")
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	if hasFrac {
+		whole += "." + frac
+	}
+	return sign + whole
+}
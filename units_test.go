@@ -0,0 +1,31 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMetersTo(t *testing.T) {
+	tests := []struct {
+		unit   string
+		meters float64
+		want   float64
+	}{
+		{"mi", 1609.344, 1},
+		{"mi", 42195, 26.218757},
+		{"km", 1000, 1},
+		{"km", 42195, 42.195},
+		{"m", 42195, 42195},
+		{"yd", 0.9144, 1},
+		{"yd", 100, 109.361330},
+		{"ft", 0.3048, 1},
+		// anything unknown is left in meters
+		{"furlong", 201.168, 201.168},
+		{"", 5, 5},
+	}
+	for _, tt := range tests {
+		if got := metersTo(tt.unit, tt.meters); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("metersTo(%q, %v) = %v, want %v", tt.unit, tt.meters, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+	"sort"
+)
+
+// VP8L alphabet sizes: green shares its alphabet with the LZ77 length
+// prefixes, and there is no color cache to add to it.
+const (
+	webpLiterals     = 256
+	webpLengthCodes  = 24
+	webpDistanceCode = 40
+	webpMaxLength    = 4096
+	webpMaxSize      = 1 << 14
+)
+
+// webpCodeLengthOrder is the order the code length code's own lengths are
+// written in.
+var webpCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// webpToken is a literal pixel or, when length is set, a copy of length
+// pixels from the one above (distance code 1) or to the left (code 2).
+type webpToken struct {
+	pixel  color.NRGBA
+	length int
+	dist   int
+}
+
+// encodeWebP writes img as a lossless WebP. It is a minimal VP8L encoder: no
+// transforms or color cache, and copies only from the pixel to the left or
+// above, which is where a chart's flat background, gridlines and bars repeat.
+func encodeWebP(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width > webpMaxSize || height > webpMaxSize {
+		return errors.New("webp: image must be 1 to 16384 pixels on each side")
+	}
+	pix := make([]color.NRGBA, 0, width*height)
+	alpha := false
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			alpha = alpha || c.A != 0xff
+			pix = append(pix, c)
+		}
+	}
+	tokens := webpTokens(pix, width)
+
+	var counts [5][]int
+	for i, n := range []int{webpLiterals + webpLengthCodes, webpLiterals, webpLiterals, webpLiterals, webpDistanceCode} {
+		counts[i] = make([]int, n)
+	}
+	for _, t := range tokens {
+		if t.length == 0 {
+			counts[0][t.pixel.G]++
+			counts[1][t.pixel.R]++
+			counts[2][t.pixel.B]++
+			counts[3][t.pixel.A]++
+			continue
+		}
+		length, _, _ := webpPrefix(t.length)
+		dist, _, _ := webpPrefix(t.dist)
+		counts[0][webpLiterals+length]++
+		counts[4][dist]++
+	}
+
+	var bw bitWriter
+	bw.write(0x2f, 8)
+	bw.write(uint64(width-1), 14)
+	bw.write(uint64(height-1), 14)
+	if alpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3) // version
+	bw.write(0, 1) // no transforms
+	bw.write(0, 1) // no color cache
+	bw.write(0, 1) // one set of prefix codes for the whole image
+	var codes [5]prefixCode
+	for i := range codes {
+		codes[i] = writePrefixCode(&bw, counts[i])
+	}
+	for _, t := range tokens {
+		if t.length == 0 {
+			codes[0].write(&bw, int(t.pixel.G))
+			codes[1].write(&bw, int(t.pixel.R))
+			codes[2].write(&bw, int(t.pixel.B))
+			codes[3].write(&bw, int(t.pixel.A))
+			continue
+		}
+		symbol, n, extra := webpPrefix(t.length)
+		codes[0].write(&bw, webpLiterals+symbol)
+		bw.write(uint64(extra), uint(n))
+		symbol, n, extra = webpPrefix(t.dist)
+		codes[4].write(&bw, symbol)
+		bw.write(uint64(extra), uint(n))
+	}
+	payload := bw.bytes()
+
+	size := len(payload) + len(payload)&1
+	header := make([]byte, 20)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+size))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(payload)))
+	if len(payload)&1 == 1 {
+		payload = append(payload, 0)
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// webpTokens splits the pixels into literals and the longest copies from the
+// pixel to the left or the row above.
+func webpTokens(pix []color.NRGBA, width int) []webpToken {
+	match := func(i, d int) int {
+		n := 0
+		for i+n < len(pix) && n < webpMaxLength && pix[i+n] == pix[i+n-d] {
+			n++
+		}
+		return n
+	}
+	var tokens []webpToken
+	for i := 0; i < len(pix); {
+		length, dist := 0, 0
+		if i >= 1 {
+			length, dist = match(i, 1), 2
+		}
+		if i >= width {
+			if n := match(i, width); n > length {
+				length, dist = n, 1
+			}
+		}
+		if length < 2 {
+			tokens = append(tokens, webpToken{pixel: pix[i]})
+			i++
+			continue
+		}
+		tokens = append(tokens, webpToken{length: length, dist: dist})
+		i += length
+	}
+	return tokens
+}
+
+// webpPrefix splits an LZ77 length or distance code v, from 1 up, into its
+// prefix symbol and the extra bits written after it.
+func webpPrefix(v int) (symbol, n, extra int) {
+	v--
+	if v < 4 {
+		return v, 0, 0
+	}
+	high := bits.Len(uint(v)) - 1
+	n = high - 1
+	return 2*high + v>>n&1, n, v & (1<<n - 1)
+}
+
+// prefixCode is a canonical Huffman code, with each code already bit
+// reversed since VP8L packs bits from the least significant end.
+type prefixCode struct {
+	codes   []uint64
+	lengths []int
+}
+
+func (c prefixCode) write(bw *bitWriter, symbol int) {
+	bw.write(c.codes[symbol], uint(c.lengths[symbol]))
+}
+
+// writePrefixCode writes the code for counts and returns it. An alphabet
+// with one symbol in use or none takes the simple form, which costs no bits
+// per symbol.
+func writePrefixCode(bw *bitWriter, counts []int) prefixCode {
+	used := []int{}
+	for s, n := range counts {
+		if n > 0 {
+			used = append(used, s)
+		}
+	}
+	if len(used) <= 1 {
+		symbol := 0
+		if len(used) == 1 {
+			symbol = used[0]
+		}
+		bw.write(1, 1) // simple
+		bw.write(0, 1) // one symbol
+		if symbol < 2 {
+			bw.write(0, 1)
+			bw.write(uint64(symbol), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint64(symbol), 8)
+		}
+		return prefixCode{codes: make([]uint64, len(counts)), lengths: make([]int, len(counts))}
+	}
+
+	lengths := huffmanLengths(counts, 15)
+	// code lengths are run length coded, 17 and 18 standing for runs of
+	// 3 to 10 and 11 to 138 zeros
+	type run struct{ symbol, n, extra int }
+	var runs []run
+	for i := 0; i < len(lengths); {
+		if lengths[i] != 0 {
+			runs = append(runs, run{symbol: lengths[i]})
+			i++
+			continue
+		}
+		zeros := 1
+		for i+zeros < len(lengths) && lengths[i+zeros] == 0 && zeros < 138 {
+			zeros++
+		}
+		switch {
+		case zeros >= 11:
+			runs = append(runs, run{18, 7, zeros - 11})
+		case zeros >= 3:
+			runs = append(runs, run{17, 3, zeros - 3})
+		default:
+			for j := 0; j < zeros; j++ {
+				runs = append(runs, run{})
+			}
+		}
+		i += zeros
+	}
+	lengthCounts := make([]int, len(webpCodeLengthOrder))
+	for _, r := range runs {
+		lengthCounts[r.symbol]++
+	}
+	lengthCode := canonicalCode(huffmanLengths(lengthCounts, 7))
+	last := 4
+	for i, s := range webpCodeLengthOrder {
+		if lengthCode.lengths[s] != 0 && i+1 > last {
+			last = i + 1
+		}
+	}
+	bw.write(0, 1) // normal
+	bw.write(uint64(last-4), 4)
+	for _, s := range webpCodeLengthOrder[:last] {
+		bw.write(uint64(lengthCode.lengths[s]), 3)
+	}
+	bw.write(0, 1) // lengths for the whole alphabet follow
+	if len(runs) > 0 && lengthCounts[runs[0].symbol] == len(runs) {
+		// one code length symbol only is read without using any bits
+		lengthCode = prefixCode{codes: lengthCode.codes, lengths: make([]int, len(lengthCode.lengths))}
+	}
+	for _, r := range runs {
+		lengthCode.write(bw, r.symbol)
+		bw.write(uint64(r.extra), uint(r.n))
+	}
+	return canonicalCode(lengths)
+}
+
+// huffmanLengths returns Huffman code lengths of at most limit bits for the
+// counts, 0 for symbols that never occur. Counts are flattened until the
+// tree is shallow enough.
+func huffmanLengths(counts []int, limit int) []int {
+	counts = append([]int(nil), counts...)
+	for {
+		type node struct {
+			count   int
+			symbols []int
+		}
+		var nodes []node
+		for s, n := range counts {
+			if n > 0 {
+				nodes = append(nodes, node{n, []int{s}})
+			}
+		}
+		lengths := make([]int, len(counts))
+		if len(nodes) == 1 {
+			lengths[nodes[0].symbols[0]] = 1
+			return lengths
+		}
+		for len(nodes) > 1 {
+			sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].count < nodes[j].count })
+			merged := node{nodes[0].count + nodes[1].count, append(append([]int(nil), nodes[0].symbols...), nodes[1].symbols...)}
+			for _, s := range merged.symbols {
+				lengths[s]++
+			}
+			nodes = append(nodes[2:], merged)
+		}
+		deepest := 0
+		for _, l := range lengths {
+			deepest = max(deepest, l)
+		}
+		if deepest <= limit {
+			return lengths
+		}
+		for s, n := range counts {
+			if n > 0 {
+				counts[s] = n/2 + 1
+			}
+		}
+	}
+}
+
+// canonicalCode assigns codes to lengths in order of length then symbol.
+func canonicalCode(lengths []int) prefixCode {
+	var perLength [16]int
+	for _, l := range lengths {
+		perLength[l]++
+	}
+	perLength[0] = 0
+	var next [16]int
+	code := 0
+	for l := 1; l < len(next); l++ {
+		code = (code + perLength[l-1]) << 1
+		next[l] = code
+	}
+	c := prefixCode{codes: make([]uint64, len(lengths)), lengths: lengths}
+	for s, l := range lengths {
+		if l > 0 {
+			c.codes[s] = uint64(bits.Reverse32(uint32(next[l])) >> (32 - l))
+			next[l]++
+		}
+	}
+	return c
+}
+
+// bitWriter packs values least significant bit first, as VP8L reads them.
+type bitWriter struct {
+	buf  []byte
+	acc  uint64
+	nacc uint
+}
+
+func (b *bitWriter) write(v uint64, n uint) {
+	b.acc |= v << b.nacc
+	b.nacc += n
+	for b.nacc >= 8 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc >>= 8
+		b.nacc -= 8
+	}
+}
+
+func (b *bitWriter) bytes() []byte {
+	if b.nacc > 0 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc, b.nacc = 0, 0
+	}
+	return b.buf
+}
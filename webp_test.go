@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	noise := image.NewNRGBA(image.Rect(0, 0, 61, 37))
+	r := rand.New(rand.NewSource(1))
+	r.Read(noise.Pix)
+	// runs long enough to need length extra bits, and a few colors
+	stripes := image.NewNRGBA(image.Rect(0, 0, 300, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 300; x++ {
+			stripes.Set(x, y, color.NRGBA{uint8(x / 50 * 40), 0x80, uint8(y / 8 * 60), 0xff})
+		}
+	}
+	flat := image.NewNRGBA(image.Rect(0, 0, 5, 3))
+	for i := range flat.Pix {
+		flat.Pix[i] = 0x40
+	}
+	tests := map[string]image.Image{
+		"noise":         noise,
+		"stripes":       stripes,
+		"flat":          flat,
+		"one pixel":     image.NewNRGBA(image.Rect(0, 0, 1, 1)),
+		"offset bounds": stripes.SubImage(image.Rect(10, 5, 120, 33)),
+	}
+	for name, img := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encodeWebP(&buf, img); err != nil {
+				t.Fatal(err)
+			}
+			got, err := webp.Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b := img.Bounds()
+			if got.Bounds().Dx() != b.Dx() || got.Bounds().Dy() != b.Dy() {
+				t.Fatalf("got %v, want %v", got.Bounds(), b)
+			}
+			for y := 0; y < b.Dy(); y++ {
+				for x := 0; x < b.Dx(); x++ {
+					want := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y))
+					if c := color.NRGBAModel.Convert(got.At(got.Bounds().Min.X+x, got.Bounds().Min.Y+y)); c != want {
+						t.Fatalf("pixel %d,%d is %v, want %v", x, y, c, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRenderWebP(t *testing.T) {
+	opts, err := parseOptions([]string{"-start=2021-01-01", "-end=2021-12-31", "-tz=UTC"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var activities Activities
+	for i := 0; i < 50; i++ {
+		activities = append(activities, Activity{Date: opts.start.AddDate(0, 0, 7*i), ActivityType: 1, Duration: 45, Distance: float64(10 + i%4)})
+	}
+	g, err := buildGraph(opts, []series{{activities: activities}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := render(g, "webp", "", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := webp.Decode(&buf); err != nil {
+		t.Fatal(err)
+	}
+}
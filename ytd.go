@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strconv"
+	"time"
+)
+
+// ytdRange returns the chart range for -ytd-compare: the whole of now's year,
+// so this year's line stops at today partway across.
+func ytdRange(now time.Time, loc *time.Location) (start, end time.Time) {
+	start = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, loc)
+	return start, start.AddDate(1, 0, 0).Add(-time.Millisecond)
+}
+
+// ytdCompare loads src for this year to date and for all of last year, with
+// last year's activities moved onto this year by day of year so the two
+// lines overlay.
+func ytdCompare(opts Options, src source, now time.Time) ([]series, error) {
+	thisYear, yearEnd := ytdRange(now, opts.location)
+	lastYear := thisYear.AddDate(-1, 0, 0)
+
+	prev := opts
+	prev.start, prev.end = lastYear, thisYear.Add(-time.Millisecond)
+	// a year with no sessions is an empty line to compare against, not an error
+	prev.minSessions = 0
+	last, err := loadActivities(prev, src.client, "me")
+	if err != nil {
+		return nil, err
+	}
+	cur := opts
+	cur.start, cur.end = thisYear, now
+	current, err := loadActivities(cur, src.client, "me")
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range last {
+		d := last[i].Date
+		timeOfDay := d.Sub(time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, d.Location()))
+		last[i].Date = thisYear.AddDate(0, 0, d.YearDay()-1).Add(timeOfDay)
+		if last[i].Date.After(yearEnd) {
+			// the 366th day of a leap year has nowhere else to go
+			last[i].Date = yearEnd
+		}
+	}
+
+	prefix := ""
+	if src.name != "" {
+		prefix = src.name + " "
+	}
+	return []series{
+		{name: prefix + strconv.Itoa(lastYear.Year()), activities: last},
+		{name: prefix + strconv.Itoa(thisYear.Year()), activities: current},
+	}, nil
+}